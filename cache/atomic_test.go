@@ -0,0 +1,192 @@
+package cache
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type AtomicTestSuite struct {
+	suite.Suite
+	caches map[string]AtomicCache
+}
+
+func TestAtomicSuite(t *testing.T) {
+	suite.Run(t, new(AtomicTestSuite))
+}
+
+// SetupTest runs before each test in the suite
+func (s *AtomicTestSuite) SetupTest() {
+	s.caches = map[string]AtomicCache{
+		"in memory": NewInMemoryCache(),
+		"lru":       NewLRUCache(10),
+		"lfu":       NewLFUCache(10),
+		"tinylfu":   NewTinyLFUCache(10),
+		"sharded":   NewShardedCache(2, 20),
+	}
+}
+
+func (s *AtomicTestSuite) TestSetNX() {
+	for name, c := range s.caches {
+		s.Run(name, func() {
+			ctx := context.Background()
+
+			added, err := c.SetNX(ctx, "key", "first", 5*time.Millisecond)
+			s.NoError(err)
+			s.True(added)
+
+			added, err = c.SetNX(ctx, "key", "second", time.Minute)
+			s.NoError(err)
+			s.False(added)
+
+			value, err := c.Get(ctx, "key")
+			s.NoError(err)
+			s.Equal("first", value)
+
+			// an expired key doesn't count
+			time.Sleep(10 * time.Millisecond)
+			added, err = c.SetNX(ctx, "key", "third", time.Minute)
+			s.NoError(err)
+			s.True(added)
+		})
+	}
+}
+
+func (s *AtomicTestSuite) TestCompareAndSwap() {
+	for name, c := range s.caches {
+		s.Run(name, func() {
+			ctx := context.Background()
+			c.SetTTL(ctx, "key", "first", time.Minute)
+
+			value, version, err := c.GetWithVersion(ctx, "key")
+			s.NoError(err)
+			s.Equal("first", value)
+
+			swapped, err := c.CompareAndSwap(ctx, "key", version, "second", time.Minute)
+			s.NoError(err)
+			s.True(swapped)
+
+			// the version changed with the value
+			swapped, err = c.CompareAndSwap(ctx, "key", version, "third", time.Minute)
+			s.NoError(err)
+			s.False(swapped)
+
+			value, err = c.Get(ctx, "key")
+			s.NoError(err)
+			s.Equal("second", value)
+		})
+	}
+}
+
+func (s *AtomicTestSuite) TestCompareAndSwap_Missing() {
+	for name, c := range s.caches {
+		s.Run(name, func() {
+			ctx := context.Background()
+
+			_, _, err := c.GetWithVersion(ctx, "key")
+			s.ErrorIs(err, CacheMissErr)
+
+			swapped, err := c.CompareAndSwap(ctx, "key", 0, "value", time.Minute)
+			s.NoError(err)
+			s.False(swapped)
+			s.Equal(uint64(0), c.Size(ctx))
+		})
+	}
+}
+
+func (s *AtomicTestSuite) TestIncrement() {
+	for name, c := range s.caches {
+		s.Run(name, func() {
+			ctx := context.Background()
+
+			n, err := c.Increment(ctx, "counter", 5, time.Minute)
+			s.NoError(err)
+			s.Equal(int64(5), n)
+
+			n, err = c.Increment(ctx, "counter", 2, time.Minute)
+			s.NoError(err)
+			s.Equal(int64(7), n)
+
+			n, err = c.Decrement(ctx, "counter", 10, time.Minute)
+			s.NoError(err)
+			s.Equal(int64(-3), n)
+
+			value, err := c.Get(ctx, "counter")
+			s.NoError(err)
+			s.Equal(int64(-3), value)
+		})
+	}
+}
+
+func (s *AtomicTestSuite) TestIncrement_KeepsTTL() {
+	for name, c := range s.caches {
+		s.Run(name, func() {
+			ctx := context.Background()
+			c.Increment(ctx, "counter", 1, 20*time.Millisecond)
+
+			time.Sleep(10 * time.Millisecond)
+			n, err := c.Increment(ctx, "counter", 1, time.Minute)
+			s.NoError(err)
+			s.Equal(int64(2), n)
+
+			// the window started with the first increment
+			time.Sleep(20 * time.Millisecond)
+			_, err = c.Get(ctx, "counter")
+			s.ErrorIs(err, CacheMissErr)
+		})
+	}
+}
+
+func (s *AtomicTestSuite) TestIncrement_NotAnInteger() {
+	for name, c := range s.caches {
+		s.Run(name, func() {
+			ctx := context.Background()
+			c.SetTTL(ctx, "key", "value", time.Minute)
+
+			_, err := c.Increment(ctx, "key", 1, time.Minute)
+			s.ErrorIs(err, TypeMismatchErr)
+		})
+	}
+}
+
+func (s *AtomicTestSuite) TestIncrement_Concurrent() {
+	for name, c := range s.caches {
+		s.Run(name, func() {
+			ctx := context.Background()
+
+			var wg sync.WaitGroup
+			for range 100 {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					c.Increment(ctx, "counter", 1, time.Minute)
+				}()
+			}
+			wg.Wait()
+
+			value, err := c.Get(ctx, "counter")
+			s.NoError(err)
+			s.Equal(int64(100), value)
+		})
+	}
+}
+
+func (s *AtomicTestSuite) TestGetAndDelete() {
+	for name, c := range s.caches {
+		s.Run(name, func() {
+			ctx := context.Background()
+			c.SetTTL(ctx, "key", "value", time.Minute)
+
+			value, err := c.GetAndDelete(ctx, "key")
+			s.NoError(err)
+			s.Equal("value", value)
+
+			_, err = c.GetAndDelete(ctx, "key")
+			s.ErrorIs(err, CacheMissErr)
+			s.Equal(uint64(0), c.Size(ctx))
+		})
+	}
+}
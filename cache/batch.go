@@ -0,0 +1,55 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// GetMany retrieves data for all the keys using the cache's batch support if it has any,
+// otherwise one key at a time. Keys that were a cache miss are left out of the result
+func GetMany(ctx context.Context, c Cache, keys []string) (map[string]interface{}, error) {
+	if bc, ok := c.(BatchCache); ok {
+		return bc.GetMany(ctx, keys)
+	}
+
+	values := make(map[string]interface{}, len(keys))
+	for _, key := range keys {
+		value, err := c.Get(ctx, key)
+		if errors.Is(err, CacheMissErr) {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		values[key] = value
+	}
+	return values, nil
+}
+
+// SetMany adds all the values using the cache's batch support if it has any, otherwise one key at a time
+func SetMany(ctx context.Context, c Cache, items map[string]interface{}, ttl time.Duration) error {
+	if bc, ok := c.(BatchCache); ok {
+		return bc.SetMany(ctx, items, ttl)
+	}
+
+	for key, value := range items {
+		if err := c.SetTTL(ctx, key, value, ttl); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DeleteMany removes all the keys using the cache's batch support if it has any, otherwise one key at a time
+func DeleteMany(ctx context.Context, c Cache, keys []string) error {
+	if bc, ok := c.(BatchCache); ok {
+		return bc.DeleteMany(ctx, keys)
+	}
+
+	for _, key := range keys {
+		if err := c.Delete(ctx, key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
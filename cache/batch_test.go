@@ -0,0 +1,89 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+// plainCache hides any optional interfaces of the wrapped cache
+type plainCache struct {
+	Cache
+}
+
+type BatchTestSuite struct {
+	suite.Suite
+	caches map[string]Cache
+}
+
+func TestBatchSuite(t *testing.T) {
+	suite.Run(t, new(BatchTestSuite))
+}
+
+// SetupTest runs before each test in the suite
+func (s *BatchTestSuite) SetupTest() {
+	s.caches = map[string]Cache{
+		"in memory": NewInMemoryCache(),
+		"lru":       NewLRUCache(10),
+		"fallback":  plainCache{NewInMemoryCache()},
+	}
+}
+
+func (s *BatchTestSuite) TestSetManyGetMany() {
+	for name, c := range s.caches {
+		s.Run(name, func() {
+			err := SetMany(context.Background(), c, map[string]interface{}{"1": 1, "2": 2, "3": 3}, time.Minute)
+			s.NoError(err)
+
+			values, err := GetMany(context.Background(), c, []string{"1", "2", "3", "does_not_exist"})
+			s.NoError(err)
+			s.Equal(map[string]interface{}{"1": 1, "2": 2, "3": 3}, values)
+		})
+	}
+}
+
+func (s *BatchTestSuite) TestGetMany_SkipsExpired() {
+	for name, c := range s.caches {
+		s.Run(name, func() {
+			c.SetTTL(context.Background(), "expired", 1, time.Millisecond)
+			c.SetTTL(context.Background(), "alive", 2, time.Minute)
+			time.Sleep(2 * time.Millisecond)
+
+			values, err := GetMany(context.Background(), c, []string{"expired", "alive"})
+			s.NoError(err)
+			s.Equal(map[string]interface{}{"alive": 2}, values)
+		})
+	}
+}
+
+func (s *BatchTestSuite) TestDeleteMany() {
+	for name, c := range s.caches {
+		s.Run(name, func() {
+			SetMany(context.Background(), c, map[string]interface{}{"1": 1, "2": 2, "3": 3}, time.Minute)
+
+			err := DeleteMany(context.Background(), c, []string{"1", "2", "does_not_exist"})
+			s.NoError(err)
+
+			_, err = c.Get(context.Background(), "1")
+			s.True(errors.Is(err, CacheMissErr))
+			s.Equal(uint64(1), c.Size(context.Background()))
+		})
+	}
+}
+
+func (s *BatchTestSuite) TestLRUSetMany_Evicts() {
+	lru := NewLRUCache(10)
+
+	items := make(map[string]interface{})
+	for i := range 15 {
+		items[strconv.Itoa(i)] = i
+	}
+
+	err := lru.SetMany(context.Background(), items, time.Minute)
+	s.NoError(err)
+	s.Equal(uint64(10), lru.Size(context.Background()))
+}
@@ -0,0 +1,142 @@
+package cache
+
+import (
+	"context"
+	"math/rand"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// benchmarkParallel runs a read heavy mix (90% gets, 10% sets) over a fixed set of keys from every P
+func benchmarkParallel(b *testing.B, c Cache) {
+	const numKeys = 10_000
+
+	keys := make([]string, numKeys)
+	for i := range keys {
+		keys[i] = "key:" + strconv.Itoa(i)
+		c.SetTTL(context.Background(), keys[i], i, time.Hour)
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		r := rand.New(rand.NewSource(time.Now().UnixNano()))
+		ctx := context.Background()
+		for pb.Next() {
+			key := keys[r.Intn(numKeys)]
+			if r.Intn(10) == 0 {
+				c.SetTTL(ctx, key, 1, time.Hour)
+			} else {
+				c.Get(ctx, key)
+			}
+		}
+	})
+}
+
+func BenchmarkInMemoryCache(b *testing.B) {
+	benchmarkParallel(b, NewInMemoryCache())
+}
+
+func BenchmarkLRUCache(b *testing.B) {
+	benchmarkParallel(b, NewLRUCache(20_000))
+}
+
+func BenchmarkShardedCache(b *testing.B) {
+	benchmarkParallel(b, NewShardedCache(0, 20_000))
+}
+
+// zipfTrace is skewed traffic where a few keys are very hot
+func zipfTrace(n int) []string {
+	r := rand.New(rand.NewSource(1))
+	zipf := rand.NewZipf(r, 1.1, 1, 99_999)
+
+	trace := make([]string, n)
+	for i := range trace {
+		trace[i] = "key:" + strconv.FormatUint(zipf.Uint64(), 10)
+	}
+	return trace
+}
+
+// scanTrace is zipfTrace where a batch job reads 5000 keys that are never used again every 20000 requests
+func scanTrace(n int) []string {
+	trace := make([]string, 0, n)
+	scans := 0
+	for i, key := range zipfTrace(n) {
+		if i > 0 && i%20_000 == 0 {
+			for j := range 5_000 {
+				trace = append(trace, "scan:"+strconv.Itoa(scans)+":"+strconv.Itoa(j))
+			}
+			scans++
+		}
+		trace = append(trace, key)
+	}
+	return trace
+}
+
+// loadTrace reads a recorded trace with one key per line, set CACHE_TRACE to benchmark against it
+func loadTrace(b *testing.B) []string {
+	path := os.Getenv("CACHE_TRACE")
+	if path == "" {
+		b.Skip("CACHE_TRACE is not set")
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		b.Fatal(err)
+	}
+	return strings.Fields(string(data))
+}
+
+// replay runs the trace through the cache, setting the key on every miss, and returns the hit ratio
+func replay(c Cache, trace []string) float64 {
+	ctx := context.Background()
+	hits := 0
+	for _, key := range trace {
+		if _, err := c.Get(ctx, key); err == nil {
+			hits++
+			continue
+		}
+		c.SetTTL(ctx, key, key, NoExpiration)
+	}
+	return float64(hits) / float64(len(trace))
+}
+
+func BenchmarkHitRatio(b *testing.B) {
+	const capacity = 1_000
+
+	caches := []struct {
+		name    string
+		newFunc func() Cache
+	}{
+		{"lru", func() Cache { return NewLRUCache(capacity) }},
+		{"lfu", func() Cache { return NewLFUCache(capacity) }},
+		{"tinylfu", func() Cache { return NewTinyLFUCache(capacity) }},
+	}
+
+	traces := []struct {
+		name  string
+		trace func(b *testing.B) []string
+	}{
+		{"zipf", func(b *testing.B) []string { return zipfTrace(200_000) }},
+		{"scan", func(b *testing.B) []string { return scanTrace(200_000) }},
+		{"recorded", loadTrace},
+	}
+
+	for _, tr := range traces {
+		b.Run(tr.name, func(b *testing.B) {
+			trace := tr.trace(b)
+			for _, c := range caches {
+				b.Run(c.name, func(b *testing.B) {
+					var ratio float64
+					for range b.N {
+						ratio = replay(c.newFunc(), trace)
+					}
+					b.ReportMetric(ratio*100, "hit%")
+				})
+			}
+		})
+	}
+}
@@ -1,14 +1,26 @@
 package cache
 
-import "context"
+import (
+	"context"
+	"io"
+	"time"
+)
+
+// NoExpiration keeps an item in the cache until it is deleted or evicted.
+// This means the same thing for every backend. A negative TTL means the item is already expired
+const NoExpiration time.Duration = 0
 
 type Cache interface {
 	// Get retrieves data given a key
 	Get(ctx context.Context, key string) (interface{}, error)
 
-	// Set adds the value for a given key
+	// Set adds the value for a given key, ttl is in seconds.
+	// Kept for compatibility, prefer SetTTL
 	Set(ctx context.Context, key string, value interface{}, ttl int) error
 
+	// SetTTL adds the value for a given key that lives for the ttl, use NoExpiration to keep it forever
+	SetTTL(ctx context.Context, key string, value interface{}, ttl time.Duration) error
+
 	// Delete removes the item from the cache given the key
 	Delete(ctx context.Context, key string) error
 
@@ -18,3 +30,103 @@ type Cache interface {
 	// Size returns the number of elements in the cache
 	Size(ctx context.Context) uint64
 }
+
+// LoadingCache is a Cache that can read through to a loader on a cache miss
+type LoadingCache interface {
+	Cache
+
+	// GetOrLoad retrieves data given a key, on a cache miss the loader is called and the result is cached.
+	// Concurrent misses for the same key only call the loader once and all receive its result or error
+	GetOrLoad(ctx context.Context, key string, ttl time.Duration, loader LoaderFunc) (interface{}, error)
+}
+
+// RefreshingCache is a LoadingCache that can keep serving a value after its ttl while it is refreshed in the background
+type RefreshingCache interface {
+	LoadingCache
+
+	// SetWithGrace adds the value for a given key that is fresh for the ttl, then stale for the grace period before it expires.
+	// Get returns stale values like any other
+	SetWithGrace(ctx context.Context, key string, value interface{}, ttl, grace time.Duration) error
+
+	// GetOrRefresh retrieves data given a key like GetOrLoad. A stale value is returned right away and the loader is called once
+	// in the background to replace it, so callers only wait on the loader when the value is missing or expired
+	GetOrRefresh(ctx context.Context, key string, ttl, grace time.Duration, loader LoaderFunc) (interface{}, error)
+}
+
+// TagCache is a Cache where items can be tagged and invalidated together, e.g. everything about a user
+type TagCache interface {
+	Cache
+
+	// SetWithTags adds the value for a given key that lives for the ttl, the item can be invalidated by any of its tags.
+	// Setting a key again replaces its tags
+	SetWithTags(ctx context.Context, key string, value interface{}, ttl time.Duration, tags ...string) error
+
+	// InvalidateTags removes every item that was set with any of the tags
+	InvalidateTags(ctx context.Context, tags ...string) error
+}
+
+// AtomicCache is a Cache that can read and write a key in a single step, e.g. for rate counters and idempotency keys
+type AtomicCache interface {
+	Cache
+
+	// SetNX adds the value only if the key doesn't exist, returning whether it was added
+	SetNX(ctx context.Context, key string, value interface{}, ttl time.Duration) (bool, error)
+
+	// GetWithVersion retrieves data given a key along with its version for CompareAndSwap
+	GetWithVersion(ctx context.Context, key string) (interface{}, uint64, error)
+
+	// CompareAndSwap replaces the value only if the key still has the version, returning whether it was replaced.
+	// A missing key is never swapped
+	CompareAndSwap(ctx context.Context, key string, version uint64, value interface{}, ttl time.Duration) (bool, error)
+
+	// Increment adds delta to the integer at key and returns the result. A missing key starts at 0 and lives for the ttl,
+	// incrementing an existing key leaves its ttl alone
+	Increment(ctx context.Context, key string, delta int64, ttl time.Duration) (int64, error)
+
+	// Decrement subtracts delta from the integer at key, see Increment
+	Decrement(ctx context.Context, key string, delta int64, ttl time.Duration) (int64, error)
+
+	// GetAndDelete retrieves data given a key and removes it
+	GetAndDelete(ctx context.Context, key string) (interface{}, error)
+}
+
+// SnapshotCache is an in-process cache that can save its items and load them back, e.g. to start warm after a deploy
+type SnapshotCache interface {
+	Cache
+
+	// Save writes every unexpired item to w with the values encoded by the codec. LRU caches keep their order
+	Save(ctx context.Context, w io.Writer, codec Codec) error
+
+	// Load adds the items saved in r, items that expired in the meantime are skipped and the rest keep their remaining ttl
+	Load(ctx context.Context, r io.Reader, codec Codec) error
+}
+
+// BatchCache is a Cache that can work with many keys in a single call
+type BatchCache interface {
+	Cache
+
+	// GetMany retrieves data for all the keys, keys that were a cache miss are left out of the result
+	GetMany(ctx context.Context, keys []string) (map[string]interface{}, error)
+
+	// SetMany adds all the values for their keys, every item lives for the ttl
+	SetMany(ctx context.Context, items map[string]interface{}, ttl time.Duration) error
+
+	// DeleteMany removes all the items from the cache given the keys
+	DeleteMany(ctx context.Context, keys []string) error
+}
+
+// PrefixCache is a Cache that can work on every key that starts with a prefix
+type PrefixCache interface {
+	Cache
+
+	// DeletePrefix removes every item whose key starts with the prefix
+	DeletePrefix(ctx context.Context, prefix string) error
+
+	// SizePrefix returns the number of items whose key starts with the prefix
+	SizePrefix(ctx context.Context, prefix string) uint64
+}
+
+// seconds converts the legacy TTL in seconds to a duration
+func seconds(ttl int) time.Duration {
+	return time.Duration(ttl) * time.Second
+}
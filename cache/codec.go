@@ -0,0 +1,73 @@
+package cache
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+)
+
+// Codec encodes and decodes values for backends that can only store bytes (e.g. redis)
+type Codec interface {
+	// Encode converts the value into bytes
+	Encode(value interface{}) ([]byte, error)
+
+	// Decode populates value (must be a pointer) from the bytes
+	Decode(data []byte, value interface{}) error
+}
+
+// JSONCodec encodes values as JSON
+type JSONCodec struct{}
+
+func (JSONCodec) Encode(value interface{}) ([]byte, error) {
+	return json.Marshal(value)
+}
+
+func (JSONCodec) Decode(data []byte, value interface{}) error {
+	return json.Unmarshal(data, value)
+}
+
+// GobCodec encodes values using encoding/gob. Values decoded into an interface{} keep their type,
+// which snapshots need, as long as it is registered with gob.Register
+type GobCodec struct{}
+
+func (GobCodec) Encode(value interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(value); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (GobCodec) Decode(data []byte, value interface{}) error {
+	return gob.NewDecoder(bytes.NewReader(data)).Decode(value)
+}
+
+func (GobCodec) keepsTypes() bool {
+	return true
+}
+
+// typedCodec is implemented by codecs that decode a value into an interface{} with the type it was encoded with.
+// Other codecs turn an int64 into a float64 or a struct into a map, embed GobCodec to use your own in a snapshot
+type typedCodec interface {
+	keepsTypes() bool
+}
+
+func keepsTypes(codec Codec) bool {
+	if tc, ok := codec.(typedCodec); ok {
+		return tc.keepsTypes()
+	}
+	return false
+}
+
+// encodingCache is implemented by caches that cannot hold arbitrary Go values
+// and need them encoded before they are stored
+type encodingCache interface {
+	requiresEncoding() bool
+}
+
+func requiresEncoding(c Cache) bool {
+	if ec, ok := c.(encodingCache); ok {
+		return ec.requiresEncoding()
+	}
+	return false
+}
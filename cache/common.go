@@ -1,21 +1,58 @@
 package cache
 
-import "time"
+import (
+	"sync/atomic"
+	"time"
+)
 
 type cacheItem struct {
-	key        string
-	value      interface{}
-	expiration int64
+	key            string
+	value          interface{}
+	expiration     int64       // unix nanoseconds, 0 never expires
+	softExpiration int64       // unix nanoseconds, after this the value is stale but can still be served until it expires
+	weight         uint64      // only tracked by caches bounded by weight
+	tags           []string    // see tagIndex
+	version        uint64      // changes every time the value does, see CompareAndSwap
+	node           interface{} // eviction policy bookkeeping, see policy
 }
 
-func newCacheItem(key string, value interface{}, ttl int64) *cacheItem {
+// newCacheItem creates an item that is fresh for the ttl and then stale for the grace period before it expires
+func newCacheItem(key string, value interface{}, ttl, grace time.Duration) *cacheItem {
+	var expiration, softExpiration int64
+	if ttl != NoExpiration {
+		softExpiration = time.Now().Add(ttl).UnixNano()
+		expiration = softExpiration + int64(grace)
+	}
+
 	return &cacheItem{
-		key:        key,
-		value:      value,
-		expiration: time.Now().Add(time.Duration(ttl) * time.Second).Unix(),
+		key:            key,
+		value:          value,
+		expiration:     expiration,
+		softExpiration: softExpiration,
+		version:        nextVersion(),
 	}
 }
 
+// versions is shared by every in-process cache, so a version is never reused for a key
+var versions atomic.Uint64
+
+func nextVersion() uint64 {
+	return versions.Add(1)
+}
+
 func (i *cacheItem) isExpired() bool {
-	return time.Now().After(time.Unix(i.expiration, 0))
+	return i.expiration != 0 && time.Now().UnixNano() >= i.expiration
+}
+
+// remaining is how long until the item expires, NoExpiration if it never does
+func (i *cacheItem) remaining() time.Duration {
+	if i.expiration == 0 {
+		return NoExpiration
+	}
+	return max(time.Duration(i.expiration-time.Now().UnixNano()), 1)
+}
+
+// isStale is true once the ttl has passed, items without a grace period expire at the same time
+func (i *cacheItem) isStale() bool {
+	return i.softExpiration != 0 && time.Now().UnixNano() >= i.softExpiration
 }
@@ -3,5 +3,10 @@ package cache
 import "errors"
 
 var (
-	CacheMissErr = errors.New("cache miss")
+	CacheMissErr    = errors.New("cache miss")
+	TypeMismatchErr = errors.New("cache value type mismatch")
+	UnsupportedErr  = errors.New("operation not supported by cache")
+
+	// UntypedCodecErr is returned by snapshots for codecs that don't keep the types of the values, e.g. JSON
+	UntypedCodecErr = errors.New("codec doesn't keep the types of the values")
 )
@@ -2,49 +2,245 @@ package cache
 
 import (
 	"context"
+	"fmt"
+	"io"
+	"strings"
 	"sync"
+	"time"
 )
 
 // InMemoryCache is a very dumb and simple cache
 // defer to LRU if possible unless you want pain
 type InMemoryCache struct {
-	cache map[string]*cacheItem
-	mu    sync.Mutex
+	cache   map[string]*cacheItem
+	tags    tagIndex
+	mu      sync.Mutex
+	loads   flightGroup
+	opts    *options
+	janitor *janitor
+	stats   counters
 }
 
-func NewInMemoryCache() *InMemoryCache {
-	return &InMemoryCache{
+func NewInMemoryCache(opts ...Option) *InMemoryCache {
+	c := &InMemoryCache{
 		cache: make(map[string]*cacheItem),
+		tags:  make(tagIndex),
+		opts:  newOptions(opts),
 	}
+
+	if c.opts.cleanupInterval > 0 {
+		c.janitor = startJanitor(c.opts.cleanupInterval, c.DeleteExpired)
+	}
+
+	return c
 }
 
 func (c *InMemoryCache) Get(ctx context.Context, key string) (interface{}, error) {
 	c.mu.Lock()
-	defer c.mu.Unlock()
+	var evictions []eviction
+	value, _, found := c.get(key, &evictions)
+	c.mu.Unlock()
 
-	item, found := c.cache[key]
-	if !found || item.isExpired() {
+	c.evicted(evictions)
+
+	if !found {
 		return nil, CacheMissErr
 	}
-
-	return item.value, nil
+	return value, nil
 }
 
 func (c *InMemoryCache) Set(ctx context.Context, key string, value interface{}, ttl int) error {
+	return c.SetTTL(ctx, key, value, seconds(ttl))
+}
+
+func (c *InMemoryCache) SetTTL(ctx context.Context, key string, value interface{}, ttl time.Duration) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.set(key, value, ttl, 0)
+
+	return nil
+}
+
+// SetWithGrace adds the value for a given key that is fresh for the ttl, then stale for the grace period before it expires
+func (c *InMemoryCache) SetWithGrace(ctx context.Context, key string, value interface{}, ttl, grace time.Duration) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.set(key, value, ttl, grace)
+
+	return nil
+}
+
+func (c *InMemoryCache) GetOrLoad(ctx context.Context, key string, ttl time.Duration, loader LoaderFunc) (interface{}, error) {
+	return getOrLoad(ctx, c, &c.loads, key, ttl, loader)
+}
+
+// GetOrRefresh retrieves data given a key, a stale value is returned while the loader refreshes it in the background
+func (c *InMemoryCache) GetOrRefresh(ctx context.Context, key string, ttl, grace time.Duration, loader LoaderFunc) (interface{}, error) {
+	return getOrRefresh(ctx, c, &c.loads, key, ttl, grace, loader)
+}
+
+// SetWithTags adds the value for a given key that lives for the ttl, the item can be invalidated by any of its tags
+func (c *InMemoryCache) SetWithTags(ctx context.Context, key string, value interface{}, ttl time.Duration, tags ...string) error {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
-	cacheItem := newCacheItem(key, value, int64(ttl))
+	c.set(key, value, ttl, 0)
+	if item, found := c.cache[key]; found && len(tags) > 0 {
+		item.tags = tags
+		c.tags.add(item)
+	}
+	return nil
+}
 
-	c.cache[key] = cacheItem
+// InvalidateTags removes every item that was set with any of the tags
+func (c *InMemoryCache) InvalidateTags(ctx context.Context, tags ...string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
 
+	for _, key := range c.tags.keys(tags) {
+		c.remove(key)
+	}
+	return nil
+}
+
+// SetNX adds the value only if the key doesn't exist, returning whether it was added
+func (c *InMemoryCache) SetNX(ctx context.Context, key string, value interface{}, ttl time.Duration) (bool, error) {
+	if ttl < 0 {
+		return false, nil
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.peek(key) != nil {
+		return false, nil
+	}
+	c.set(key, value, ttl, 0)
+	return true, nil
+}
+
+// GetWithVersion retrieves data given a key along with its version for CompareAndSwap
+func (c *InMemoryCache) GetWithVersion(ctx context.Context, key string) (interface{}, uint64, error) {
+	c.mu.Lock()
+	var evictions []eviction
+	var version uint64
+	value, _, found := c.get(key, &evictions)
+	if found {
+		version = c.cache[key].version
+	}
+	c.mu.Unlock()
+
+	c.evicted(evictions)
+
+	if !found {
+		return nil, 0, CacheMissErr
+	}
+	return value, version, nil
+}
+
+// CompareAndSwap replaces the value only if the key still has the version, returning whether it was replaced
+func (c *InMemoryCache) CompareAndSwap(ctx context.Context, key string, version uint64, value interface{}, ttl time.Duration) (bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if item := c.peek(key); item == nil || item.version != version {
+		return false, nil
+	}
+	c.set(key, value, ttl, 0)
+	return true, nil
+}
+
+// Increment adds delta to the int64 at key and returns the result, a missing key starts at 0 and lives for the ttl
+func (c *InMemoryCache) Increment(ctx context.Context, key string, delta int64, ttl time.Duration) (int64, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	item := c.peek(key)
+	if item == nil {
+		c.set(key, delta, ttl, 0)
+		return delta, nil
+	}
+
+	n, ok := item.value.(int64)
+	if !ok {
+		return 0, fmt.Errorf("%w: expected int64, got %T", TypeMismatchErr, item.value)
+	}
+
+	// updated in place so the ttl is left alone
+	item.value = n + delta
+	item.version = nextVersion()
+	c.stats.sets.Add(1)
+	return n + delta, nil
+}
+
+// Decrement subtracts delta from the int64 at key, see Increment
+func (c *InMemoryCache) Decrement(ctx context.Context, key string, delta int64, ttl time.Duration) (int64, error) {
+	return c.Increment(ctx, key, -delta, ttl)
+}
+
+// GetAndDelete retrieves data given a key and removes it
+func (c *InMemoryCache) GetAndDelete(ctx context.Context, key string) (interface{}, error) {
+	c.mu.Lock()
+	var evictions []eviction
+	value, _, found := c.get(key, &evictions)
+	if found {
+		c.remove(key)
+	}
+	c.mu.Unlock()
+
+	c.evicted(evictions)
+
+	if !found {
+		return nil, CacheMissErr
+	}
+	return value, nil
+}
+
+// GetMany retrieves data for all the keys, keys that were a cache miss are left out of the result
+func (c *InMemoryCache) GetMany(ctx context.Context, keys []string) (map[string]interface{}, error) {
+	values := make(map[string]interface{}, len(keys))
+
+	c.mu.Lock()
+	var evictions []eviction
+	for _, key := range keys {
+		if value, _, found := c.get(key, &evictions); found {
+			values[key] = value
+		}
+	}
+	c.mu.Unlock()
+
+	c.evicted(evictions)
+	return values, nil
+}
+
+// SetMany adds all the values for their keys, every item lives for the ttl
+func (c *InMemoryCache) SetMany(ctx context.Context, items map[string]interface{}, ttl time.Duration) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for key, value := range items {
+		c.set(key, value, ttl, 0)
+	}
 	return nil
 }
 
 func (c *InMemoryCache) Delete(ctx context.Context, key string) error {
 	c.mu.Lock()
 	defer c.mu.Unlock()
-	delete(c.cache, key)
+	c.remove(key)
+	return nil
+}
+
+// DeleteMany removes all the items from the cache given the keys
+func (c *InMemoryCache) DeleteMany(ctx context.Context, keys []string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, key := range keys {
+		c.remove(key)
+	}
 	return nil
 }
 
@@ -53,9 +249,13 @@ func (c *InMemoryCache) Purge(ctx context.Context) {
 	defer c.mu.Unlock()
 
 	c.cache = make(map[string]*cacheItem)
+	c.tags = make(tagIndex)
 }
 
 func (c *InMemoryCache) Size(ctx context.Context) uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
 	var size uint64
 	for _, item := range c.cache {
 		if !item.isExpired() {
@@ -64,3 +264,201 @@ func (c *InMemoryCache) Size(ctx context.Context) uint64 {
 	}
 	return size
 }
+
+// DeleteExpired removes every expired item from the cache.
+// This is what the janitor runs, but it can be called manually too
+func (c *InMemoryCache) DeleteExpired() {
+	c.mu.Lock()
+	var evictions []eviction
+	for key, item := range c.cache {
+		if item.isExpired() {
+			c.remove(key)
+			evictions = append(evictions, eviction{item: item, reason: ExpiredEviction})
+		}
+	}
+	c.mu.Unlock()
+
+	c.evicted(evictions)
+}
+
+// Close stops the background janitor if one was started
+func (c *InMemoryCache) Close() error {
+	c.janitor.Stop()
+	return nil
+}
+
+// Save writes every unexpired item to w with the values encoded by the codec
+func (c *InMemoryCache) Save(ctx context.Context, w io.Writer, codec Codec) error {
+	return writeSnapshot(w, codec, c.snapshot())
+}
+
+// Load adds the items saved in r, items that expired in the meantime are skipped and the rest keep their remaining ttl
+func (c *InMemoryCache) Load(ctx context.Context, r io.Reader, codec Codec) error {
+	items, err := readSnapshot(r, codec)
+	if err != nil {
+		return err
+	}
+	c.restore(items)
+	return nil
+}
+
+// DeletePrefix removes every item whose key starts with the prefix
+func (c *InMemoryCache) DeletePrefix(ctx context.Context, prefix string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for key := range c.cache {
+		if strings.HasPrefix(key, prefix) {
+			c.remove(key)
+		}
+	}
+	return nil
+}
+
+// SizePrefix returns the number of items whose key starts with the prefix
+func (c *InMemoryCache) SizePrefix(ctx context.Context, prefix string) uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var size uint64
+	for key, item := range c.cache {
+		if strings.HasPrefix(key, prefix) && !item.isExpired() {
+			size++
+		}
+	}
+	return size
+}
+
+// Stats returns a snapshot of the cache's counters
+func (c *InMemoryCache) Stats(ctx context.Context) Stats {
+	stats := c.stats.snapshot()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for key, item := range c.cache {
+		if !item.isExpired() {
+			stats.Size++
+			stats.Bytes += estimateSize(key, item.value)
+		}
+	}
+	return stats
+}
+
+// snapshot copies every unexpired item
+func (c *InMemoryCache) snapshot() []cacheItem {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	items := make([]cacheItem, 0, len(c.cache))
+	for _, item := range c.cache {
+		if !item.isExpired() {
+			items = append(items, *item)
+		}
+	}
+	return items
+}
+
+// restore adds the items loaded from a snapshot
+func (c *InMemoryCache) restore(items []*cacheItem) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, item := range items {
+		c.add(item)
+	}
+}
+
+// evicted records and reports items the cache removed, must be called without holding the lock
+func (c *InMemoryCache) evicted(evictions []eviction) {
+	c.stats.evicted(evictions)
+	c.opts.notify(evictions)
+}
+
+// getWithTTL retrieves data given a key and how long until it expires
+func (c *InMemoryCache) getWithTTL(ctx context.Context, key string) (interface{}, time.Duration, error) {
+	c.mu.Lock()
+	var evictions []eviction
+	value, _, found := c.get(key, &evictions)
+	var ttl time.Duration
+	if found {
+		ttl = c.peek(key).remaining()
+	}
+	c.mu.Unlock()
+
+	c.evicted(evictions)
+
+	if !found {
+		return nil, 0, CacheMissErr
+	}
+	return value, ttl, nil
+}
+
+// getStale retrieves data given a key and whether the value is past its ttl
+func (c *InMemoryCache) getStale(ctx context.Context, key string) (interface{}, bool, error) {
+	c.mu.Lock()
+	var evictions []eviction
+	value, stale, found := c.get(key, &evictions)
+	c.mu.Unlock()
+
+	c.evicted(evictions)
+
+	if !found {
+		return nil, false, CacheMissErr
+	}
+	return value, stale, nil
+}
+
+// peek returns the unexpired item for the key without counting a hit or miss, must be called while holding the lock
+func (c *InMemoryCache) peek(key string) *cacheItem {
+	if item, found := c.cache[key]; found && !item.isExpired() {
+		return item
+	}
+	return nil
+}
+
+// get must be called while holding the lock, expired items are removed and added to evictions
+func (c *InMemoryCache) get(key string, evictions *[]eviction) (interface{}, bool, bool) {
+	item, found := c.cache[key]
+	if !found {
+		c.stats.hit(false)
+		return nil, false, false
+	}
+
+	if item.isExpired() {
+		c.remove(key)
+		*evictions = append(*evictions, eviction{item: item, reason: ExpiredEviction})
+		c.stats.hit(false)
+		return nil, false, false
+	}
+
+	c.stats.hit(true)
+	return item.value, item.isStale(), true
+}
+
+// set must be called while holding the lock, the item is stale for the grace period after the ttl
+func (c *InMemoryCache) set(key string, value interface{}, ttl, grace time.Duration) {
+	// already expired, make sure an older value doesn't stick around
+	if ttl < 0 {
+		c.remove(key)
+		return
+	}
+
+	c.add(newCacheItem(key, value, ttl, grace))
+}
+
+// add must be called while holding the lock, any item with the same key is replaced along with its tags
+func (c *InMemoryCache) add(item *cacheItem) {
+	c.remove(item.key)
+	c.cache[item.key] = item
+	c.tags.add(item)
+	c.stats.sets.Add(1)
+}
+
+// remove must be called while holding the lock
+func (c *InMemoryCache) remove(key string) {
+	if item, found := c.cache[key]; found {
+		delete(c.cache, key)
+		c.tags.remove(item)
+	}
+}
@@ -4,7 +4,9 @@ import (
 	"context"
 	"errors"
 	"strconv"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/suite"
 )
@@ -29,6 +31,7 @@ func (s *InMemoryCacheTestSuite) SetupTest() {
 
 // TearDownTest runs after each test in the suite
 func (s *InMemoryCacheTestSuite) TearDownTest() {
+	s.cache.Close()
 }
 
 func (s *InMemoryCacheTestSuite) TestInMemory_SetGet() {
@@ -94,3 +97,98 @@ func (s *InMemoryCacheTestSuite) TestInMemory_SizeAndPurge() {
 		s.T().Errorf("expected 0, but got %v", s.cache.Size(context.Background()))
 	}
 }
+
+func (s *InMemoryCacheTestSuite) TestInMemory_ExpiredRemovedOnGet() {
+	var evicted []string
+	s.cache = NewInMemoryCache(WithEvictionCallback(func(key string, value interface{}, reason EvictionReason) {
+		s.Equal(ExpiredEviction, reason)
+		evicted = append(evicted, key)
+	}))
+
+	s.cache.SetTTL(context.Background(), "1", 1, time.Millisecond)
+	time.Sleep(2 * time.Millisecond)
+
+	_, err := s.cache.Get(context.Background(), "1")
+	if !errors.Is(err, CacheMissErr) {
+		s.T().Errorf("expected error %v, got %v", CacheMissErr, err)
+	}
+
+	s.Empty(s.cache.cache)
+	s.Equal([]string{"1"}, evicted)
+}
+
+func (s *InMemoryCacheTestSuite) TestInMemory_DeleteExpired() {
+	var evicted []string
+	s.cache = NewInMemoryCache(WithEvictionCallback(func(key string, value interface{}, reason EvictionReason) {
+		s.Equal(ExpiredEviction, reason)
+		evicted = append(evicted, key)
+	}))
+
+	s.cache.SetTTL(context.Background(), "expired", 1, time.Millisecond)
+	s.cache.Set(context.Background(), "alive", 2, 60)
+	time.Sleep(2 * time.Millisecond)
+
+	s.cache.DeleteExpired()
+
+	s.Len(s.cache.cache, 1)
+	s.Equal([]string{"expired"}, evicted)
+}
+
+func (s *InMemoryCacheTestSuite) TestInMemory_Janitor() {
+	var mu sync.Mutex
+	var evicted []string
+	s.cache = NewInMemoryCache(
+		WithCleanupInterval(10*time.Millisecond),
+		WithEvictionCallback(func(key string, value interface{}, reason EvictionReason) {
+			mu.Lock()
+			defer mu.Unlock()
+			evicted = append(evicted, key)
+		}),
+	)
+
+	for i := range 5 {
+		s.cache.SetTTL(context.Background(), strconv.Itoa(i), i, time.Millisecond)
+	}
+
+	s.Eventually(func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(evicted) == 5
+	}, time.Second, 10*time.Millisecond)
+
+	s.cache.mu.Lock()
+	s.Empty(s.cache.cache)
+	s.cache.mu.Unlock()
+
+	// closing more than once is safe
+	s.NoError(s.cache.Close())
+	s.NoError(s.cache.Close())
+}
+
+func (s *InMemoryCacheTestSuite) TestInMemory_TTL() {
+	s.cache.SetTTL(context.Background(), "forever", 1, NoExpiration)
+	s.cache.Set(context.Background(), "legacy_forever", 1, 0)
+	s.cache.SetTTL(context.Background(), "short", 1, 20*time.Millisecond)
+	s.cache.SetTTL(context.Background(), "expired", 1, -time.Second)
+
+	s.Equal(uint64(3), s.cache.Size(context.Background()))
+
+	time.Sleep(30 * time.Millisecond)
+
+	_, err := s.cache.Get(context.Background(), "forever")
+	s.NoError(err)
+	_, err = s.cache.Get(context.Background(), "legacy_forever")
+	s.NoError(err)
+	_, err = s.cache.Get(context.Background(), "short")
+	s.True(errors.Is(err, CacheMissErr))
+	_, err = s.cache.Get(context.Background(), "expired")
+	s.True(errors.Is(err, CacheMissErr))
+}
+
+func (s *InMemoryCacheTestSuite) TestInMemory_NegativeTTLRemovesExisting() {
+	s.cache.Set(context.Background(), "key", 1, 60)
+	s.cache.SetTTL(context.Background(), "key", 2, -time.Second)
+
+	_, err := s.cache.Get(context.Background(), "key")
+	s.True(errors.Is(err, CacheMissErr))
+}
@@ -0,0 +1,49 @@
+package cache
+
+import (
+	"sync"
+	"time"
+)
+
+// janitor periodically runs a sweep in the background until stopped
+type janitor struct {
+	stop     chan struct{}
+	done     chan struct{}
+	stopOnce sync.Once
+}
+
+func startJanitor(interval time.Duration, sweep func()) *janitor {
+	j := &janitor{
+		stop: make(chan struct{}),
+		done: make(chan struct{}),
+	}
+
+	go func() {
+		defer close(j.done)
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				sweep()
+			case <-j.stop:
+				return
+			}
+		}
+	}()
+
+	return j
+}
+
+// Stop stops the janitor and waits for a running sweep to finish. Safe to call more than once
+func (j *janitor) Stop() {
+	if j == nil {
+		return
+	}
+	j.stopOnce.Do(func() {
+		close(j.stop)
+	})
+	<-j.done
+}
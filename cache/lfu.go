@@ -0,0 +1,110 @@
+package cache
+
+import "container/list"
+
+// lfuBucket holds every item that has been used freq times, most recently used at the front
+type lfuBucket struct {
+	freq  uint64
+	items *list.List
+}
+
+// lfuNode is where an item lives in the lfuPolicy
+type lfuNode struct {
+	bucket  *list.Element // element of lfuPolicy.buckets
+	element *list.Element // element of lfuBucket.items
+}
+
+// lfuPolicy evicts the least frequently used item, ties go to the least recently used.
+// Every operation is O(1) by keeping a list of frequency buckets in ascending order
+type lfuPolicy struct {
+	capacity uint64
+	size     uint64
+	buckets  *list.List
+}
+
+// NewLFUCache creates a cache that evicts the least frequently used items once it holds capacity items.
+// Frequently used items survive one off scans that would flush an LRU cache
+func NewLFUCache(capacity uint64, opts ...Option) *policyCache {
+	return newPolicyCache(&lfuPolicy{
+		capacity: validCapacity(capacity),
+		buckets:  list.New(),
+	}, opts)
+}
+
+func (p *lfuPolicy) add(item *cacheItem) []*cacheItem {
+	// evict before adding so the new item isn't the one picked
+	var victims []*cacheItem
+	if p.size >= p.capacity {
+		if victim := p.victim(); victim != nil {
+			p.remove(victim)
+			victims = append(victims, victim)
+		}
+	}
+
+	front := p.buckets.Front()
+	if front == nil || front.Value.(*lfuBucket).freq != 1 {
+		front = p.buckets.PushFront(&lfuBucket{freq: 1, items: list.New()})
+	}
+
+	item.node = &lfuNode{
+		bucket:  front,
+		element: front.Value.(*lfuBucket).items.PushFront(item),
+	}
+	p.size++
+
+	return victims
+}
+
+func (p *lfuPolicy) hit(item *cacheItem) {
+	node := item.node.(*lfuNode)
+	current := node.bucket.Value.(*lfuBucket)
+
+	// move to the bucket for freq+1, creating it if needed
+	next := node.bucket.Next()
+	if next == nil || next.Value.(*lfuBucket).freq != current.freq+1 {
+		next = p.buckets.InsertAfter(&lfuBucket{freq: current.freq + 1, items: list.New()}, node.bucket)
+	}
+
+	current.items.Remove(node.element)
+	if current.items.Len() == 0 {
+		p.buckets.Remove(node.bucket)
+	}
+
+	node.bucket = next
+	node.element = next.Value.(*lfuBucket).items.PushFront(item)
+}
+
+func (p *lfuPolicy) replace(item *cacheItem, oldWeight uint64) []*cacheItem {
+	p.hit(item)
+	return nil
+}
+
+func (p *lfuPolicy) remove(item *cacheItem) {
+	node, ok := item.node.(*lfuNode)
+	if !ok {
+		return
+	}
+
+	bucket := node.bucket.Value.(*lfuBucket)
+	bucket.items.Remove(node.element)
+	if bucket.items.Len() == 0 {
+		p.buckets.Remove(node.bucket)
+	}
+
+	item.node = nil
+	p.size--
+}
+
+func (p *lfuPolicy) reset() {
+	p.buckets = list.New()
+	p.size = 0
+}
+
+// victim is the least recently used item of the lowest frequency
+func (p *lfuPolicy) victim() *cacheItem {
+	front := p.buckets.Front()
+	if front == nil {
+		return nil
+	}
+	return front.Value.(*lfuBucket).items.Back().Value.(*cacheItem)
+}
@@ -0,0 +1,143 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type LFUTestSuite struct {
+	suite.Suite
+	cache *policyCache
+}
+
+func TestLFUTestSuite(t *testing.T) {
+	suite.Run(t, new(LFUTestSuite))
+}
+
+// SetupTest runs before each test in the suite
+func (s *LFUTestSuite) SetupTest() {
+	s.cache = NewLFUCache(10)
+}
+
+// TearDownTest runs after each test in the suite
+func (s *LFUTestSuite) TearDownTest() {
+	s.cache.Close()
+}
+
+func (s *LFUTestSuite) TestLFUCache_SetGet() {
+	s.cache.SetTTL(context.Background(), "key", "value", time.Minute)
+
+	value, err := s.cache.Get(context.Background(), "key")
+	s.NoError(err)
+	s.Equal("value", value)
+
+	s.cache.SetTTL(context.Background(), "key", "new value", time.Minute)
+
+	value, err = s.cache.Get(context.Background(), "key")
+	s.NoError(err)
+	s.Equal("new value", value)
+	s.Equal(uint64(1), s.cache.Size(context.Background()))
+}
+
+func (s *LFUTestSuite) TestLFUCache_EvictsLeastFrequentlyUsed() {
+	for i := range 10 {
+		s.cache.SetTTL(context.Background(), strconv.Itoa(i), i, time.Minute)
+	}
+
+	// everything but "5" is used again
+	for i := range 10 {
+		if i != 5 {
+			s.cache.Get(context.Background(), strconv.Itoa(i))
+		}
+	}
+
+	s.cache.SetTTL(context.Background(), "new", 10, time.Minute)
+
+	_, err := s.cache.Get(context.Background(), "5")
+	s.True(errors.Is(err, CacheMissErr))
+	_, err = s.cache.Get(context.Background(), "new")
+	s.NoError(err)
+	s.Equal(uint64(10), s.cache.Size(context.Background()))
+}
+
+func (s *LFUTestSuite) TestLFUCache_TiesEvictLeastRecentlyUsed() {
+	var evicted []string
+	s.cache = NewLFUCache(10, WithEvictionCallback(func(key string, value interface{}, reason EvictionReason) {
+		s.Equal(CapacityEviction, reason)
+		evicted = append(evicted, key)
+	}))
+
+	for i := range 12 {
+		s.cache.SetTTL(context.Background(), strconv.Itoa(i), i, time.Minute)
+	}
+
+	s.Equal([]string{"0", "1"}, evicted)
+}
+
+func (s *LFUTestSuite) TestLFUCache_SurvivesScan() {
+	for i := range 5 {
+		key := "hot:" + strconv.Itoa(i)
+		s.cache.SetTTL(context.Background(), key, i, time.Minute)
+		for range 3 {
+			s.cache.Get(context.Background(), key)
+		}
+	}
+
+	for i := range 100 {
+		s.cache.SetTTL(context.Background(), "scan:"+strconv.Itoa(i), i, time.Minute)
+	}
+
+	for i := range 5 {
+		_, err := s.cache.Get(context.Background(), "hot:"+strconv.Itoa(i))
+		s.NoError(err)
+	}
+}
+
+func (s *LFUTestSuite) TestLFUCache_DeleteExpiredAndPurge() {
+	for i := range 5 {
+		s.cache.SetTTL(context.Background(), strconv.Itoa(i), i, time.Millisecond)
+	}
+	s.cache.SetTTL(context.Background(), "alive", 1, time.Minute)
+	time.Sleep(2 * time.Millisecond)
+
+	s.cache.DeleteExpired()
+	s.Equal(uint64(1), s.cache.Size(context.Background()))
+	s.Equal(uint64(5), s.cache.Stats(context.Background()).Expirations)
+
+	s.cache.Purge(context.Background())
+	s.Equal(uint64(0), s.cache.Size(context.Background()))
+
+	// the policy is reset too so the cache can fill up again
+	for i := range 10 {
+		s.cache.SetTTL(context.Background(), strconv.Itoa(i), i, time.Minute)
+	}
+	s.Equal(uint64(10), s.cache.Size(context.Background()))
+	s.Equal(uint64(0), s.cache.Stats(context.Background()).Evictions)
+}
+
+func (s *LFUTestSuite) TestLFUCache_Delete() {
+	s.cache.SetTTL(context.Background(), "key", "value", time.Minute)
+
+	err := s.cache.Delete(context.Background(), "key")
+	s.NoError(err)
+
+	_, err = s.cache.Get(context.Background(), "key")
+	s.True(errors.Is(err, CacheMissErr))
+	s.Equal(0, s.cache.policy.(*lfuPolicy).buckets.Len())
+}
+
+func (s *LFUTestSuite) TestLFUCache_SizeSkipsExpired() {
+	s.cache.SetTTL(context.Background(), "key:live", 1, time.Minute)
+	s.cache.SetTTL(context.Background(), "key:dead", 1, time.Millisecond)
+	time.Sleep(2 * time.Millisecond)
+
+	// nothing read or removed the expired item, it just isn't counted
+	s.Equal(uint64(1), s.cache.Size(context.Background()))
+	s.Equal(uint64(1), s.cache.SizePrefix(context.Background(), "key:"))
+	s.Equal(uint64(1), s.cache.Stats(context.Background()).Size)
+}
@@ -0,0 +1,39 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// LoaderFunc loads the value for a key from the source of truth (e.g. a DB) on a cache miss
+type LoaderFunc func(ctx context.Context, key string) (interface{}, error)
+
+// getOrLoad returns the cached value or calls the loader and caches the result.
+// Concurrent misses for the same key share a single loader call
+func getOrLoad(ctx context.Context, c Cache, group *flightGroup, key string, ttl time.Duration, loader LoaderFunc) (interface{}, error) {
+	value, err := c.Get(ctx, key)
+	if err == nil {
+		return value, nil
+	}
+
+	// a broken cache shouldn't take down the caller, fall back to the loader
+	if !errors.Is(err, CacheMissErr) {
+		log.Err(err).Str("key", key).Msg("failed to get from cache, falling back to loader")
+	}
+
+	return group.do(ctx, key, func(ctx context.Context) (interface{}, error) {
+		value, err := loader(ctx, key)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := c.SetTTL(ctx, key, value, ttl); err != nil {
+			log.Err(err).Str("key", key).Msg("failed to cache loaded value")
+		}
+
+		return value, nil
+	})
+}
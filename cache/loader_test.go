@@ -0,0 +1,215 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/meowmix1337/go-core/derror"
+	"github.com/stretchr/testify/suite"
+)
+
+type LoaderTestSuite struct {
+	suite.Suite
+	caches map[string]LoadingCache
+}
+
+func TestLoaderSuite(t *testing.T) {
+	suite.Run(t, new(LoaderTestSuite))
+}
+
+// SetupTest runs before each test in the suite
+func (s *LoaderTestSuite) SetupTest() {
+	s.caches = map[string]LoadingCache{
+		"in memory": NewInMemoryCache(),
+		"lru":       NewLRUCache(10),
+	}
+}
+
+func (s *LoaderTestSuite) TestGetOrLoad_Hit() {
+	for name, c := range s.caches {
+		s.Run(name, func() {
+			c.Set(context.Background(), "key", "cached", 5)
+
+			value, err := c.GetOrLoad(context.Background(), "key", time.Minute, func(ctx context.Context, key string) (interface{}, error) {
+				s.Fail("loader should not be called on a hit")
+				return nil, nil
+			})
+			s.NoError(err)
+			s.Equal("cached", value)
+		})
+	}
+}
+
+func (s *LoaderTestSuite) TestGetOrLoad_MissSetsValue() {
+	for name, c := range s.caches {
+		s.Run(name, func() {
+			value, err := c.GetOrLoad(context.Background(), "key", time.Minute, func(ctx context.Context, key string) (interface{}, error) {
+				return "loaded " + key, nil
+			})
+			s.NoError(err)
+			s.Equal("loaded key", value)
+
+			value, err = c.Get(context.Background(), "key")
+			s.NoError(err)
+			s.Equal("loaded key", value)
+		})
+	}
+}
+
+func (s *LoaderTestSuite) TestGetOrLoad_ConcurrentMissesLoadOnce() {
+	for name, c := range s.caches {
+		s.Run(name, func() {
+			var calls atomic.Int32
+			release := make(chan struct{})
+
+			loader := func(ctx context.Context, key string) (interface{}, error) {
+				calls.Add(1)
+				<-release
+				return "loaded", nil
+			}
+
+			var wg sync.WaitGroup
+			results := make(chan interface{}, 50)
+			for range 50 {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					value, err := c.GetOrLoad(context.Background(), "key", time.Minute, loader)
+					s.NoError(err)
+					results <- value
+				}()
+			}
+
+			// give the callers time to pile up behind the first loader
+			time.Sleep(50 * time.Millisecond)
+			close(release)
+			wg.Wait()
+			close(results)
+
+			s.Equal(int32(1), calls.Load())
+			for value := range results {
+				s.Equal("loaded", value)
+			}
+		})
+	}
+}
+
+func (s *LoaderTestSuite) TestGetOrLoad_ErrorPropagatesToWaiters() {
+	for name, c := range s.caches {
+		s.Run(name, func() {
+			loadErr := derror.New(context.Background(), derror.InternalServerCode, derror.InternalType, "failed to load", errors.New("db down"))
+			release := make(chan struct{})
+
+			loader := func(ctx context.Context, key string) (interface{}, error) {
+				<-release
+				return nil, loadErr
+			}
+
+			var wg sync.WaitGroup
+			for range 10 {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					_, err := c.GetOrLoad(context.Background(), "key", time.Minute, loader)
+
+					var derr *derror.Error
+					s.True(errors.As(err, &derr))
+					s.Equal(loadErr, derr)
+				}()
+			}
+
+			time.Sleep(50 * time.Millisecond)
+			close(release)
+			wg.Wait()
+
+			// errors are not cached
+			_, err := c.Get(context.Background(), "key")
+			s.True(errors.Is(err, CacheMissErr))
+		})
+	}
+}
+
+func (s *LoaderTestSuite) TestGetOrLoad_WaiterContextCancelled() {
+	c := NewInMemoryCache()
+	release := make(chan struct{})
+	defer close(release)
+
+	go c.GetOrLoad(context.Background(), "key", time.Minute, func(ctx context.Context, key string) (interface{}, error) {
+		<-release
+		return "loaded", nil
+	})
+	time.Sleep(10 * time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	_, err := c.GetOrLoad(ctx, "key", time.Minute, func(ctx context.Context, key string) (interface{}, error) {
+		s.Fail("only the first caller should load")
+		return nil, nil
+	})
+	s.True(errors.Is(err, context.DeadlineExceeded))
+}
+
+func (s *LoaderTestSuite) TestGetOrLoad_FirstCallerCancelled() {
+	for name, c := range s.caches {
+		s.Run(name, func() {
+			started := make(chan struct{})
+			release := make(chan struct{})
+			loader := func(ctx context.Context, key string) (interface{}, error) {
+				close(started)
+				select {
+				case <-release:
+					return "loaded", nil
+				case <-ctx.Done():
+					return nil, ctx.Err()
+				}
+			}
+
+			ctx, cancel := context.WithCancel(context.Background())
+			first := make(chan error, 1)
+			go func() {
+				_, err := c.GetOrLoad(ctx, "key", time.Minute, loader)
+				first <- err
+			}()
+			<-started
+
+			second := make(chan interface{}, 1)
+			go func() {
+				value, err := c.GetOrLoad(context.Background(), "key", time.Minute, loader)
+				s.NoError(err)
+				second <- value
+			}()
+			time.Sleep(10 * time.Millisecond)
+
+			// the first caller stops waiting right away, the load carries on for the second
+			cancel()
+			s.True(errors.Is(<-first, context.Canceled))
+
+			close(release)
+			s.Equal("loaded", <-second)
+
+			value, err := c.Get(context.Background(), "key")
+			s.NoError(err)
+			s.Equal("loaded", value)
+		})
+	}
+}
+
+func (s *LoaderTestSuite) TestTypedGetOrLoad() {
+	typed := NewTypedCache[user](NewLRUCache(10))
+	expected := user{ID: 42, Name: "dave"}
+
+	value, err := typed.GetOrLoad(context.Background(), "user:42", time.Minute, func(ctx context.Context, key string) (user, error) {
+		return expected, nil
+	})
+	s.NoError(err)
+	s.Equal(expected, value)
+
+	value, err = typed.Get(context.Background(), "user:42")
+	s.NoError(err)
+	s.Equal(expected, value)
+}
@@ -2,9 +2,7 @@ package cache
 
 import (
 	"container/list"
-	"context"
 	"fmt"
-	"sync"
 
 	"github.com/rs/zerolog/log"
 )
@@ -14,128 +12,97 @@ const (
 	DefaultCapacity uint64 = 500
 )
 
-type lruCache struct {
-	mu        sync.RWMutex
-	capacity  uint64
-	cache     map[string]*list.Element
-	cacheList *list.List // doubly linked list
+// lruPolicy evicts the least recently used items once there are more than capacity of them,
+// or once their total weight is over the byte budget (WithMaxBytes)
+type lruPolicy struct {
+	capacity uint64
+	maxBytes uint64
+	weight   uint64     // total weight of every item in the list
+	items    *list.List // most recently used at the front
 }
 
-func NewLRUCache(capacity uint64) *lruCache {
-	if capacity < MinimumCapacity {
-		log.Warn().Msg(fmt.Sprintf("minimum capacity is %v, but got %v. Defaulting to %v", MinimumCapacity, capacity, DefaultCapacity))
-		capacity = DefaultCapacity
-	}
-	cache := &lruCache{
-		capacity:  capacity,
-		cache:     make(map[string]*list.Element),
-		cacheList: list.New(),
+// NewLRUCache creates a cache that evicts the least recently used items once it holds capacity items
+func NewLRUCache(capacity uint64, opts ...Option) *policyCache {
+	p := &lruPolicy{
+		capacity: validCapacity(capacity),
+		items:    list.New(),
 	}
-
-	return cache
+	c := newPolicyCache(p, opts)
+	p.maxBytes = c.opts.maxBytes
+	return c
 }
 
-func (c *lruCache) Get(ctx context.Context, key string) (interface{}, error) {
-	c.mu.RLock()
-	defer c.mu.RUnlock()
-
-	element, found := c.cache[key]
-
-	if !found || element == nil {
-		return nil, CacheMissErr
-	}
-
-	cacheItem := element.Value.(*cacheItem)
-
-	// delete expired
-	if cacheItem.isExpired() {
-		// unlock the read lock and acquire write lock
-		c.mu.RUnlock()
-		c.mu.Lock()
-		c.removeElement(element)
-		// now we need to unlock the write and re-acquire the read lock since we'll defer unlock it
-		c.mu.Unlock()
-		c.mu.RLock()
-		return nil, CacheMissErr
+// validCapacity defaults capacities below the minimum, shared by the bounded caches
+func validCapacity(capacity uint64) uint64 {
+	if capacity < MinimumCapacity {
+		log.Warn().Msg(fmt.Sprintf("minimum capacity is %v, but got %v. Defaulting to %v", MinimumCapacity, capacity, DefaultCapacity))
+		return DefaultCapacity
 	}
-
-	// if exist, move it to the front
-	// must not be expired
-	// if already exists, move to front of list
-	c.cacheList.MoveToFront(element)
-	return cacheItem.value, nil
-
+	return capacity
 }
 
-func (c *lruCache) Set(ctx context.Context, key string, value interface{}, ttl int) error {
-	c.mu.Lock()
-	defer c.mu.Unlock()
-
-	// if already exists, move to front of list
-	if element, moved := c.moveToFront(key); moved {
-		// update the expiration since we've access the existing element
-		element.Value = newCacheItem(key, value, int64(ttl))
-		return nil
-	}
-
-	// create new item
-	newCacheItem := newCacheItem(key, value, int64(ttl))
-	newElement := c.cacheList.PushFront(newCacheItem)
-	c.cache[key] = newElement
-
-	// if new size is larger than capacity, evict last element
-	if c.Size(ctx) > c.capacity {
-		c.evict()
+func (p *lruPolicy) add(item *cacheItem) []*cacheItem {
+	// an item heavier than the whole budget would flush the cache and still not fit, so it is evicted right away
+	if p.tooHeavy(item.weight) {
+		return []*cacheItem{item}
 	}
 
-	return nil
+	item.node = p.items.PushFront(item)
+	p.weight += item.weight
+	return p.evict()
 }
 
-func (c *lruCache) Delete(ctx context.Context, key string) error {
-	c.mu.Lock()
-	defer c.mu.Unlock()
+func (p *lruPolicy) hit(item *cacheItem) {
+	p.items.MoveToFront(item.node.(*list.Element))
+}
 
-	element := c.cache[key]
-	if element != nil {
-		c.cacheList.Remove(element)
-		delete(c.cache, element.Value.(*cacheItem).key)
+func (p *lruPolicy) replace(item *cacheItem, oldWeight uint64) []*cacheItem {
+	p.weight += item.weight - oldWeight
+	if p.tooHeavy(item.weight) {
+		p.remove(item)
+		return []*cacheItem{item}
 	}
 
-	return nil
+	p.hit(item)
+	return p.evict()
 }
 
-func (c *lruCache) Purge(ctx context.Context) {
-	c.mu.Lock()
-	defer c.mu.Unlock()
+func (p *lruPolicy) remove(item *cacheItem) {
+	element, ok := item.node.(*list.Element)
+	if !ok {
+		return
+	}
 
-	c.cache = make(map[string]*list.Element)
-	c.cacheList = list.New()
+	p.items.Remove(element)
+	p.weight -= item.weight
+	item.node = nil
 }
 
-func (c *lruCache) Size(ctx context.Context) uint64 {
-	return uint64(c.cacheList.Len())
+func (p *lruPolicy) reset() {
+	p.items = list.New()
+	p.weight = 0
 }
 
-func (c *lruCache) moveToFront(key string) (*list.Element, bool) {
-	// if already exists, move to front of list
-	if element, found := c.cache[key]; found {
-		c.cacheList.MoveToFront(element)
-		return element, true
+// ordered returns the items from least to most recently used, so restoring them in order keeps the LRU order
+func (p *lruPolicy) ordered() []*cacheItem {
+	items := make([]*cacheItem, 0, p.items.Len())
+	for element := p.items.Back(); element != nil; element = element.Prev() {
+		items = append(items, element.Value.(*cacheItem))
 	}
-	return nil, false
+	return items
 }
 
-func (c *lruCache) evict() {
-	// get the last element in the list, remove it from the list and the map
-	lastElement := c.cacheList.Back()
-	if lastElement != nil {
-		c.removeElement(lastElement)
-		return
+// evict removes items from the back until we are within capacity and the byte budget
+func (p *lruPolicy) evict() []*cacheItem {
+	var victims []*cacheItem
+	for uint64(p.items.Len()) > p.capacity || (p.maxBytes > 0 && p.weight > p.maxBytes) {
+		victim := p.items.Back().Value.(*cacheItem)
+		p.remove(victim)
+		victims = append(victims, victim)
 	}
-	log.Debug().Msg("last element was nil, nothing to evict")
+	return victims
 }
 
-func (c *lruCache) removeElement(element *list.Element) {
-	delete(c.cache, element.Value.(*cacheItem).key)
-	c.cacheList.Remove(element)
+func (p *lruPolicy) tooHeavy(weight uint64) bool {
+	return p.maxBytes > 0 && weight > p.maxBytes
 }
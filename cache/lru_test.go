@@ -4,14 +4,16 @@ import (
 	"context"
 	"errors"
 	"strconv"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/suite"
 )
 
 type LRUTestSuite struct {
 	suite.Suite
-	cache *lruCache
+	cache *policyCache
 }
 
 func TestLRUTestSuite(t *testing.T) {
@@ -29,6 +31,7 @@ func (s *LRUTestSuite) SetupTest() {
 
 // TearDownTest runs after each test in the suite
 func (s *LRUTestSuite) TearDownTest() {
+	s.cache.Close()
 }
 
 func (s *LRUTestSuite) Test_SetGet() {
@@ -138,12 +141,14 @@ func (s *LRUTestSuite) TestLRUCache_Eviction() {
 
 func (s *LRUTestSuite) TestLRUCache_Expired() {
 
-	s.cache.Set(context.Background(), "1", 1, 0)
+	s.cache.SetTTL(context.Background(), "1", 1, time.Millisecond)
 
 	if s.cache.Size(context.Background()) != 1 {
 		s.T().Errorf("expected 1, but got %v", s.cache.Size(context.Background()))
 	}
 
+	time.Sleep(2 * time.Millisecond)
+
 	_, err := s.cache.Get(context.Background(), "1")
 	if !errors.Is(err, CacheMissErr) {
 		s.T().Errorf("expected error %v, got %v", CacheMissErr, err)
@@ -153,3 +158,145 @@ func (s *LRUTestSuite) TestLRUCache_Expired() {
 		s.T().Errorf("expected 0, but got %v", s.cache.Size(context.Background()))
 	}
 }
+
+func (s *LRUTestSuite) TestLRUCache_TTL() {
+	s.cache.SetTTL(context.Background(), "forever", 1, NoExpiration)
+	s.cache.Set(context.Background(), "legacy_forever", 1, 0)
+	s.cache.SetTTL(context.Background(), "short", 1, 20*time.Millisecond)
+	s.cache.SetTTL(context.Background(), "expired", 1, -time.Second)
+
+	s.Equal(uint64(3), s.cache.Size(context.Background()))
+
+	time.Sleep(30 * time.Millisecond)
+
+	_, err := s.cache.Get(context.Background(), "forever")
+	s.NoError(err)
+	_, err = s.cache.Get(context.Background(), "legacy_forever")
+	s.NoError(err)
+	_, err = s.cache.Get(context.Background(), "short")
+	s.True(errors.Is(err, CacheMissErr))
+	_, err = s.cache.Get(context.Background(), "expired")
+	s.True(errors.Is(err, CacheMissErr))
+}
+
+func (s *LRUTestSuite) TestLRUCache_EvictionCallback() {
+	var evicted []string
+	var reasons []EvictionReason
+	s.cache = NewLRUCache(10, WithEvictionCallback(func(key string, value interface{}, reason EvictionReason) {
+		evicted = append(evicted, key)
+		reasons = append(reasons, reason)
+	}))
+
+	for i := range 11 {
+		s.cache.Set(context.Background(), strconv.Itoa(i), i, 60)
+	}
+	s.cache.SetTTL(context.Background(), "expired", 1, time.Millisecond)
+	time.Sleep(2 * time.Millisecond)
+	s.cache.Get(context.Background(), "expired")
+
+	// "1" is evicted to make room for "expired" since "0" was evicted for "10"
+	s.Equal([]string{"0", "1", "expired"}, evicted)
+	s.Equal([]EvictionReason{CapacityEviction, CapacityEviction, ExpiredEviction}, reasons)
+}
+
+func (s *LRUTestSuite) TestLRUCache_Janitor() {
+	var mu sync.Mutex
+	var evicted int
+	s.cache = NewLRUCache(10,
+		WithCleanupInterval(10*time.Millisecond),
+		WithEvictionCallback(func(key string, value interface{}, reason EvictionReason) {
+			mu.Lock()
+			defer mu.Unlock()
+			evicted++
+		}),
+	)
+
+	for i := range 5 {
+		s.cache.SetTTL(context.Background(), strconv.Itoa(i), i, time.Millisecond)
+	}
+	s.cache.Set(context.Background(), "alive", 1, 60)
+
+	s.Eventually(func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return evicted == 5
+	}, time.Second, 10*time.Millisecond)
+
+	s.Equal(uint64(1), s.cache.Size(context.Background()))
+
+	value, err := s.cache.Get(context.Background(), "alive")
+	s.NoError(err)
+	s.Equal(1, value)
+}
+
+func (s *LRUTestSuite) TestLRUCache_MaxBytes() {
+	var evicted []string
+	s.cache = NewLRUCache(100,
+		WithMaxBytes(100),
+		WithWeigher(func(key string, value interface{}) uint64 {
+			return uint64(len(value.([]byte)))
+		}),
+		WithEvictionCallback(func(key string, value interface{}, reason EvictionReason) {
+			s.Equal(CapacityEviction, reason)
+			evicted = append(evicted, key)
+		}),
+	)
+
+	s.cache.SetTTL(context.Background(), "a", make([]byte, 40), time.Minute)
+	s.cache.SetTTL(context.Background(), "b", make([]byte, 40), time.Minute)
+	s.cache.Get(context.Background(), "a") // b is now the least recently used
+
+	// 40 + 40 + 30 is over budget so b goes
+	s.cache.SetTTL(context.Background(), "c", make([]byte, 30), time.Minute)
+	s.Equal([]string{"b"}, evicted)
+	s.Equal(uint64(70), s.cache.Stats(context.Background()).Bytes)
+
+	// growing an existing item also evicts
+	s.cache.SetTTL(context.Background(), "c", make([]byte, 80), time.Minute)
+	s.Equal([]string{"b", "a"}, evicted)
+	s.Equal(uint64(80), s.cache.Stats(context.Background()).Bytes)
+	s.Equal(uint64(1), s.cache.Size(context.Background()))
+}
+
+func (s *LRUTestSuite) TestLRUCache_MaxBytesItemTooLarge() {
+	s.cache = NewLRUCache(100,
+		WithMaxBytes(100),
+		WithWeigher(func(key string, value interface{}) uint64 {
+			return uint64(len(value.([]byte)))
+		}),
+	)
+
+	s.cache.SetTTL(context.Background(), "small", make([]byte, 10), time.Minute)
+	s.cache.SetTTL(context.Background(), "huge", make([]byte, 101), time.Minute)
+
+	// the huge item isn't stored and doesn't flush everything else
+	_, err := s.cache.Get(context.Background(), "huge")
+	s.True(errors.Is(err, CacheMissErr))
+	_, err = s.cache.Get(context.Background(), "small")
+	s.NoError(err)
+	s.Equal(uint64(10), s.cache.Stats(context.Background()).Bytes)
+	s.Equal(uint64(1), s.cache.Stats(context.Background()).Evictions)
+}
+
+func (s *LRUTestSuite) TestLRUCache_WeightTracksRemovals() {
+	s.cache.SetTTL(context.Background(), "a", "value", time.Minute)
+	s.cache.SetTTL(context.Background(), "b", "value", time.Minute)
+	s.Equal(2*estimateSize("a", "value"), s.cache.Stats(context.Background()).Bytes)
+
+	s.cache.Delete(context.Background(), "a")
+	s.Equal(estimateSize("b", "value"), s.cache.Stats(context.Background()).Bytes)
+
+	s.cache.Purge(context.Background())
+	s.Equal(uint64(0), s.cache.Stats(context.Background()).Bytes)
+}
+
+func (s *LRUTestSuite) TestLRUCache_SizeSkipsExpired() {
+	s.cache.SetTTL(context.Background(), "key:live", 1, time.Minute)
+	s.cache.SetTTL(context.Background(), "key:dead", 1, time.Millisecond)
+	time.Sleep(2 * time.Millisecond)
+
+	// nothing read or removed the expired item, it just isn't counted
+	s.Equal(uint64(1), s.cache.Size(context.Background()))
+	s.Equal(uint64(1), s.cache.SizePrefix(context.Background(), "key:"))
+	s.Equal(uint64(1), s.cache.Stats(context.Background()).Size)
+}
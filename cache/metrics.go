@@ -0,0 +1,106 @@
+package cache
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/rs/zerolog/log"
+)
+
+// metric describes a single Prometheus metric derived from Stats
+type metric struct {
+	name       string
+	help       string
+	metricType string
+	value      func(s Stats) float64
+}
+
+var metrics = []metric{
+	{"cache_hits_total", "Number of reads that found the key.", "counter", func(s Stats) float64 { return float64(s.Hits) }},
+	{"cache_misses_total", "Number of reads that did not find the key.", "counter", func(s Stats) float64 { return float64(s.Misses) }},
+	{"cache_sets_total", "Number of items written.", "counter", func(s Stats) float64 { return float64(s.Sets) }},
+	{"cache_evictions_total", "Number of items removed to make room.", "counter", func(s Stats) float64 { return float64(s.Evictions) }},
+	{"cache_expirations_total", "Number of items removed because their TTL passed.", "counter", func(s Stats) float64 { return float64(s.Expirations) }},
+	{"cache_items", "Number of items currently in the cache.", "gauge", func(s Stats) float64 { return float64(s.Size) }},
+	{"cache_bytes", "Estimated bytes used by the cache.", "gauge", func(s Stats) float64 { return float64(s.Bytes) }},
+	{"cache_hit_ratio", "Hits divided by hits and misses.", "gauge", func(s Stats) float64 { return s.HitRatio() }},
+}
+
+// Collector gathers the stats of named caches and exposes them in the Prometheus text format
+type Collector struct {
+	mu     sync.RWMutex
+	caches map[string]StatsCache
+}
+
+func NewCollector() *Collector {
+	return &Collector{
+		caches: make(map[string]StatsCache),
+	}
+}
+
+// Register adds the cache under the name, which becomes the "cache" label. Registering a name again replaces it
+func (c *Collector) Register(name string, cache StatsCache) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.caches[name] = cache
+}
+
+// Unregister removes the cache with the name
+func (c *Collector) Unregister(name string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.caches, name)
+}
+
+// Collect returns the stats of every registered cache keyed by name
+func (c *Collector) Collect(ctx context.Context) map[string]Stats {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	stats := make(map[string]Stats, len(c.caches))
+	for name, cache := range c.caches {
+		stats[name] = cache.Stats(ctx)
+	}
+	return stats
+}
+
+// WriteText writes the stats of every registered cache in the Prometheus text exposition format
+func (c *Collector) WriteText(ctx context.Context, w io.Writer) error {
+	stats := c.Collect(ctx)
+
+	names := make([]string, 0, len(stats))
+	for name := range stats {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	buf := bufio.NewWriter(w)
+	for _, m := range metrics {
+		fmt.Fprintf(buf, "# HELP %s %s\n", m.name, m.help)
+		fmt.Fprintf(buf, "# TYPE %s %s\n", m.name, m.metricType)
+		for _, name := range names {
+			fmt.Fprintf(buf, "%s{cache=\"%s\"} %v\n", m.name, escapeLabel(name), m.value(stats[name]))
+		}
+	}
+	return buf.Flush()
+}
+
+// ServeHTTP lets the collector be mounted as a /metrics endpoint
+func (c *Collector) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	if err := c.WriteText(r.Context(), w); err != nil {
+		log.Err(err).Msg("failed to write cache metrics")
+	}
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escapeLabel(value string) string {
+	return labelEscaper.Replace(value)
+}
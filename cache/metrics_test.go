@@ -0,0 +1,52 @@
+package cache
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCollector_WriteText(t *testing.T) {
+	lru := NewLRUCache(10)
+	lru.SetTTL(context.Background(), "key", "value", time.Minute)
+	lru.Get(context.Background(), "key")
+	lru.Get(context.Background(), "does_not_exist")
+
+	collector := NewCollector()
+	collector.Register("lru", lru)
+	collector.Register(`in "memory"`, NewInMemoryCache())
+
+	var buf bytes.Buffer
+	err := collector.WriteText(context.Background(), &buf)
+	assert.NoError(t, err)
+
+	output := buf.String()
+	assert.Contains(t, output, "# HELP cache_hits_total Number of reads that found the key.\n# TYPE cache_hits_total counter\n")
+	assert.Contains(t, output, `cache_hits_total{cache="in \"memory\""} 0`+"\n"+`cache_hits_total{cache="lru"} 1`+"\n")
+	assert.Contains(t, output, `cache_misses_total{cache="lru"} 1`)
+	assert.Contains(t, output, `cache_sets_total{cache="lru"} 1`)
+	assert.Contains(t, output, `cache_items{cache="lru"} 1`)
+	assert.Contains(t, output, `cache_hit_ratio{cache="lru"} 0.5`)
+
+	collector.Unregister("lru")
+	buf.Reset()
+	collector.WriteText(context.Background(), &buf)
+	assert.NotContains(t, buf.String(), `cache="lru"`)
+}
+
+func TestCollector_ServeHTTP(t *testing.T) {
+	collector := NewCollector()
+	collector.Register("lru", NewLRUCache(10))
+
+	recorder := httptest.NewRecorder()
+	collector.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Equal(t, "text/plain; version=0.0.4; charset=utf-8", recorder.Header().Get("Content-Type"))
+	assert.Contains(t, recorder.Body.String(), `cache_items{cache="lru"} 0`)
+}
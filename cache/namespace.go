@@ -0,0 +1,146 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// namespacedCache is a view of a cache where every key is prefixed,
+// so many apps can share one cache without stepping on each other
+type namespacedCache struct {
+	cache  Cache
+	prefix string
+	loads  flightGroup
+}
+
+// Namespace returns a view of the cache that prefixes every key (e.g. "orders:").
+// Purge and Size only touch keys in the namespace, which needs the cache to be a PrefixCache.
+// An empty prefix is a passthrough to the whole cache, Purge and Size included.
+// The view is only a Cache, LoadingCache, BatchCache and PrefixCache, tags, grace periods and the atomic
+// operations of the cache aren't available through it
+func Namespace(c Cache, prefix string) *namespacedCache {
+	return &namespacedCache{
+		cache:  c,
+		prefix: prefix,
+	}
+}
+
+// Get retrieves data given a key
+func (c *namespacedCache) Get(ctx context.Context, key string) (interface{}, error) {
+	return c.cache.Get(ctx, c.key(key))
+}
+
+// Set adds the value for a given key, ttl is in seconds
+func (c *namespacedCache) Set(ctx context.Context, key string, value interface{}, ttl int) error {
+	return c.SetTTL(ctx, key, value, seconds(ttl))
+}
+
+// SetTTL adds the value for a given key that lives for the ttl
+func (c *namespacedCache) SetTTL(ctx context.Context, key string, value interface{}, ttl time.Duration) error {
+	return c.cache.SetTTL(ctx, c.key(key), value, ttl)
+}
+
+// GetOrLoad retrieves data given a key, calling the loader once on a cache miss
+func (c *namespacedCache) GetOrLoad(ctx context.Context, key string, ttl time.Duration, loader LoaderFunc) (interface{}, error) {
+	return getOrLoad(ctx, c, &c.loads, key, ttl, loader)
+}
+
+// GetMany retrieves data for all the keys, keys that were a cache miss are left out of the result
+func (c *namespacedCache) GetMany(ctx context.Context, keys []string) (map[string]interface{}, error) {
+	values, err := GetMany(ctx, c.cache, c.keys(keys))
+	if err != nil {
+		return nil, err
+	}
+
+	unprefixed := make(map[string]interface{}, len(values))
+	for key, value := range values {
+		unprefixed[strings.TrimPrefix(key, c.prefix)] = value
+	}
+	return unprefixed, nil
+}
+
+// SetMany adds all the values for their keys, every item lives for the ttl
+func (c *namespacedCache) SetMany(ctx context.Context, items map[string]interface{}, ttl time.Duration) error {
+	prefixed := make(map[string]interface{}, len(items))
+	for key, value := range items {
+		prefixed[c.key(key)] = value
+	}
+	return SetMany(ctx, c.cache, prefixed, ttl)
+}
+
+// Delete removes the item from the cache given the key
+func (c *namespacedCache) Delete(ctx context.Context, key string) error {
+	return c.cache.Delete(ctx, c.key(key))
+}
+
+// DeleteMany removes all the items from the cache given the keys
+func (c *namespacedCache) DeleteMany(ctx context.Context, keys []string) error {
+	return DeleteMany(ctx, c.cache, c.keys(keys))
+}
+
+// Purge clears only the items in the namespace.
+// If the cache can't delete by prefix nothing is removed rather than clearing everyone's keys
+func (c *namespacedCache) Purge(ctx context.Context) {
+	if c.prefix == "" {
+		c.cache.Purge(ctx)
+		return
+	}
+	if err := deletePrefix(ctx, c.cache, c.prefix); err != nil {
+		log.Err(err).Str("prefix", c.prefix).Msg("failed to purge namespace")
+	}
+}
+
+// Size returns the number of items in the namespace
+func (c *namespacedCache) Size(ctx context.Context) uint64 {
+	if c.prefix == "" {
+		return c.cache.Size(ctx)
+	}
+	return sizePrefix(ctx, c.cache, c.prefix)
+}
+
+// DeletePrefix removes every item in the namespace whose key starts with the prefix
+func (c *namespacedCache) DeletePrefix(ctx context.Context, prefix string) error {
+	return deletePrefix(ctx, c.cache, c.key(prefix))
+}
+
+// SizePrefix returns the number of items in the namespace whose key starts with the prefix
+func (c *namespacedCache) SizePrefix(ctx context.Context, prefix string) uint64 {
+	return sizePrefix(ctx, c.cache, c.key(prefix))
+}
+
+func (c *namespacedCache) requiresEncoding() bool {
+	return requiresEncoding(c.cache)
+}
+
+func (c *namespacedCache) key(key string) string {
+	return c.prefix + key
+}
+
+func (c *namespacedCache) keys(keys []string) []string {
+	prefixed := make([]string, len(keys))
+	for i, key := range keys {
+		prefixed[i] = c.key(key)
+	}
+	return prefixed
+}
+
+func deletePrefix(ctx context.Context, c Cache, prefix string) error {
+	pc, ok := c.(PrefixCache)
+	if !ok {
+		return fmt.Errorf("%w: %T can't delete by prefix", UnsupportedErr, c)
+	}
+	return pc.DeletePrefix(ctx, prefix)
+}
+
+func sizePrefix(ctx context.Context, c Cache, prefix string) uint64 {
+	pc, ok := c.(PrefixCache)
+	if !ok {
+		log.Error().Str("prefix", prefix).Msgf("%T can't count by prefix", c)
+		return 0
+	}
+	return pc.SizePrefix(ctx, prefix)
+}
@@ -0,0 +1,137 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type NamespaceTestSuite struct {
+	suite.Suite
+	backends map[string]Cache
+}
+
+func TestNamespaceSuite(t *testing.T) {
+	suite.Run(t, new(NamespaceTestSuite))
+}
+
+// SetupTest runs before each test in the suite
+func (s *NamespaceTestSuite) SetupTest() {
+	s.backends = map[string]Cache{
+		"in memory": NewInMemoryCache(),
+		"lru":       NewLRUCache(10),
+	}
+}
+
+func (s *NamespaceTestSuite) TestNamespace_PrefixesKeys() {
+	for name, backend := range s.backends {
+		s.Run(name, func() {
+			orders := Namespace(backend, "orders:")
+
+			err := orders.SetTTL(context.Background(), "1", "order", time.Minute)
+			s.NoError(err)
+
+			value, err := backend.Get(context.Background(), "orders:1")
+			s.NoError(err)
+			s.Equal("order", value)
+
+			value, err = orders.Get(context.Background(), "1")
+			s.NoError(err)
+			s.Equal("order", value)
+
+			err = orders.Delete(context.Background(), "1")
+			s.NoError(err)
+
+			_, err = backend.Get(context.Background(), "orders:1")
+			s.True(errors.Is(err, CacheMissErr))
+		})
+	}
+}
+
+func (s *NamespaceTestSuite) TestNamespace_PurgeAndSizeOnlyTouchNamespace() {
+	for name, backend := range s.backends {
+		s.Run(name, func() {
+			orders := Namespace(backend, "orders:")
+			users := Namespace(backend, "users:")
+
+			orders.SetTTL(context.Background(), "1", 1, time.Minute)
+			orders.SetTTL(context.Background(), "2", 2, time.Minute)
+			users.SetTTL(context.Background(), "1", 1, time.Minute)
+			backend.SetTTL(context.Background(), "global", 1, time.Minute)
+
+			s.Equal(uint64(2), orders.Size(context.Background()))
+			s.Equal(uint64(1), users.Size(context.Background()))
+
+			orders.Purge(context.Background())
+
+			s.Equal(uint64(0), orders.Size(context.Background()))
+			s.Equal(uint64(1), users.Size(context.Background()))
+			s.Equal(uint64(2), backend.Size(context.Background()))
+		})
+	}
+}
+
+func (s *NamespaceTestSuite) TestNamespace_Batch() {
+	for name, backend := range s.backends {
+		s.Run(name, func() {
+			orders := Namespace(backend, "orders:")
+
+			err := orders.SetMany(context.Background(), map[string]interface{}{"1": 1, "2": 2}, time.Minute)
+			s.NoError(err)
+
+			values, err := orders.GetMany(context.Background(), []string{"1", "2", "3"})
+			s.NoError(err)
+			s.Equal(map[string]interface{}{"1": 1, "2": 2}, values)
+
+			err = orders.DeleteMany(context.Background(), []string{"1", "2"})
+			s.NoError(err)
+			s.Equal(uint64(0), backend.Size(context.Background()))
+		})
+	}
+}
+
+func (s *NamespaceTestSuite) TestNamespace_Nested() {
+	backend := NewInMemoryCache()
+	eu := Namespace(Namespace(backend, "orders:"), "eu:")
+
+	eu.SetTTL(context.Background(), "1", 1, time.Minute)
+	backend.SetTTL(context.Background(), "orders:us:1", 1, time.Minute)
+
+	_, err := backend.Get(context.Background(), "orders:eu:1")
+	s.NoError(err)
+
+	eu.Purge(context.Background())
+	s.Equal(uint64(1), backend.Size(context.Background()))
+}
+
+func (s *NamespaceTestSuite) TestNamespace_UnsupportedPurgeKeepsEverything() {
+	backend := NewInMemoryCache()
+	orders := Namespace(plainCache{backend}, "orders:")
+
+	orders.SetTTL(context.Background(), "1", 1, time.Minute)
+	backend.SetTTL(context.Background(), "global", 1, time.Minute)
+
+	orders.Purge(context.Background())
+	s.Equal(uint64(2), backend.Size(context.Background()))
+
+	err := orders.DeletePrefix(context.Background(), "")
+	s.True(errors.Is(err, UnsupportedErr))
+}
+
+func (s *NamespaceTestSuite) TestNamespace_EmptyPrefix() {
+	ctx := context.Background()
+	c := NewInMemoryCache()
+	whole := Namespace(c, "")
+
+	s.NoError(whole.SetTTL(ctx, "key", "value", time.Minute))
+	value, err := c.Get(ctx, "key")
+	s.NoError(err)
+	s.Equal("value", value)
+	s.Equal(uint64(1), whole.Size(ctx))
+
+	whole.Purge(ctx)
+	s.Equal(uint64(0), c.Size(ctx))
+}
@@ -0,0 +1,104 @@
+package cache
+
+import "time"
+
+// EvictionReason describes why the cache removed an item on its own
+type EvictionReason int
+
+const (
+	// ExpiredEviction means the item's TTL passed
+	ExpiredEviction EvictionReason = iota + 1
+	// CapacityEviction means the item was removed to make room for a new one
+	CapacityEviction
+)
+
+func (r EvictionReason) String() string {
+	switch r {
+	case ExpiredEviction:
+		return "expired"
+	case CapacityEviction:
+		return "capacity"
+	default:
+		return "unknown"
+	}
+}
+
+// EvictFunc is called whenever the cache removes an item on its own.
+// It is not called for Delete or Purge
+type EvictFunc func(key string, value interface{}, reason EvictionReason)
+
+// Weigher returns the cost of an item, usually its size in bytes
+type Weigher func(key string, value interface{}) uint64
+
+// Option configures the in-process caches
+type Option func(*options)
+
+type options struct {
+	cleanupInterval time.Duration
+	onEvict         EvictFunc
+	weigher         Weigher
+	maxBytes        uint64
+}
+
+func newOptions(opts []Option) *options {
+	o := &options{}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// WithCleanupInterval starts a background janitor that removes expired items every interval.
+// Call Close on the cache to stop it
+func WithCleanupInterval(interval time.Duration) Option {
+	return func(o *options) {
+		o.cleanupInterval = interval
+	}
+}
+
+// WithEvictionCallback registers a function that is called when an item is evicted
+func WithEvictionCallback(fn EvictFunc) Option {
+	return func(o *options) {
+		o.onEvict = fn
+	}
+}
+
+// WithWeigher sets how the weight of an item is calculated for WithMaxBytes.
+// Defaults to a rough estimate of the key and value size
+func WithWeigher(fn Weigher) Option {
+	return func(o *options) {
+		o.weigher = fn
+	}
+}
+
+// WithMaxBytes bounds the total weight of the items in the cache, the least recently used items are
+// evicted until everything fits. The capacity still bounds the number of items. Only used by the LRU cache
+func WithMaxBytes(maxBytes uint64) Option {
+	return func(o *options) {
+		o.maxBytes = maxBytes
+	}
+}
+
+func (o *options) weigh(key string, value interface{}) uint64 {
+	if o.weigher != nil {
+		return o.weigher(key, value)
+	}
+	return estimateSize(key, value)
+}
+
+// eviction is an item that was removed by the cache and still needs to be reported
+type eviction struct {
+	item   *cacheItem
+	reason EvictionReason
+}
+
+// notify calls the eviction callback, must be called without holding the cache's lock
+// so the callback is free to use the cache
+func (o *options) notify(evictions []eviction) {
+	if o.onEvict == nil {
+		return
+	}
+	for _, e := range evictions {
+		o.onEvict(e.item.key, e.item.value, e.reason)
+	}
+}
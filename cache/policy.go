@@ -0,0 +1,557 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+)
+
+// policy decides which items a policyCache keeps once it is full.
+// Every method is called while holding the cache's lock
+type policy interface {
+	// add tracks a new item and returns the items that should be evicted to make room,
+	// which can include the new item if the policy decides it isn't worth keeping
+	add(item *cacheItem) []*cacheItem
+
+	// hit is called when an existing item is read
+	hit(item *cacheItem)
+
+	// replace is called when an existing item gets a new value, oldWeight is what it weighed before.
+	// Returns the items that should be evicted, which can include the item itself
+	replace(item *cacheItem, oldWeight uint64) []*cacheItem
+
+	// remove stops tracking an item the cache removed on its own or through Delete
+	remove(item *cacheItem)
+
+	// reset stops tracking every item
+	reset()
+}
+
+// orderedPolicy is implemented by policies whose order is kept in snapshots
+type orderedPolicy interface {
+	// ordered returns every item in the order to restore them in
+	ordered() []*cacheItem
+}
+
+// policyCache is a bounded in-process cache where the eviction policy is pluggable, e.g. LRU, LFU or W-TinyLFU
+type policyCache struct {
+	mu      sync.Mutex
+	items   map[string]*cacheItem
+	tags    tagIndex
+	policy  policy
+	loads   flightGroup
+	opts    *options
+	janitor *janitor
+	stats   counters
+}
+
+func newPolicyCache(p policy, opts []Option) *policyCache {
+	c := &policyCache{
+		items:  make(map[string]*cacheItem),
+		tags:   make(tagIndex),
+		policy: p,
+		opts:   newOptions(opts),
+	}
+
+	if c.opts.cleanupInterval > 0 {
+		c.janitor = startJanitor(c.opts.cleanupInterval, c.DeleteExpired)
+	}
+
+	return c
+}
+
+// Get retrieves data given a key
+func (c *policyCache) Get(ctx context.Context, key string) (interface{}, error) {
+	c.mu.Lock()
+	var evictions []eviction
+	value, _, found := c.get(key, &evictions)
+	c.mu.Unlock()
+
+	c.evicted(evictions)
+
+	if !found {
+		return nil, CacheMissErr
+	}
+	return value, nil
+}
+
+// Set adds the value for a given key, ttl is in seconds
+func (c *policyCache) Set(ctx context.Context, key string, value interface{}, ttl int) error {
+	return c.SetTTL(ctx, key, value, seconds(ttl))
+}
+
+// SetTTL adds the value for a given key that lives for the ttl
+func (c *policyCache) SetTTL(ctx context.Context, key string, value interface{}, ttl time.Duration) error {
+	c.mu.Lock()
+	var evictions []eviction
+	c.set(key, value, ttl, 0, &evictions)
+	c.mu.Unlock()
+
+	c.evicted(evictions)
+	return nil
+}
+
+// SetWithGrace adds the value for a given key that is fresh for the ttl, then stale for the grace period before it expires
+func (c *policyCache) SetWithGrace(ctx context.Context, key string, value interface{}, ttl, grace time.Duration) error {
+	c.mu.Lock()
+	var evictions []eviction
+	c.set(key, value, ttl, grace, &evictions)
+	c.mu.Unlock()
+
+	c.evicted(evictions)
+	return nil
+}
+
+// GetOrLoad retrieves data given a key, calling the loader once on a cache miss
+func (c *policyCache) GetOrLoad(ctx context.Context, key string, ttl time.Duration, loader LoaderFunc) (interface{}, error) {
+	return getOrLoad(ctx, c, &c.loads, key, ttl, loader)
+}
+
+// GetOrRefresh retrieves data given a key, a stale value is returned while the loader refreshes it in the background
+func (c *policyCache) GetOrRefresh(ctx context.Context, key string, ttl, grace time.Duration, loader LoaderFunc) (interface{}, error) {
+	return getOrRefresh(ctx, c, &c.loads, key, ttl, grace, loader)
+}
+
+// SetWithTags adds the value for a given key that lives for the ttl, the item can be invalidated by any of its tags
+func (c *policyCache) SetWithTags(ctx context.Context, key string, value interface{}, ttl time.Duration, tags ...string) error {
+	c.mu.Lock()
+	var evictions []eviction
+	c.set(key, value, ttl, 0, &evictions)
+	// the policy might not have admitted the item
+	if item, found := c.items[key]; found && len(tags) > 0 {
+		item.tags = tags
+		c.tags.add(item)
+	}
+	c.mu.Unlock()
+
+	c.evicted(evictions)
+	return nil
+}
+
+// InvalidateTags removes every item that was set with any of the tags
+func (c *policyCache) InvalidateTags(ctx context.Context, tags ...string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, key := range c.tags.keys(tags) {
+		c.removeItem(c.items[key])
+	}
+	return nil
+}
+
+// SetNX adds the value only if the key doesn't exist, returning whether it was added
+func (c *policyCache) SetNX(ctx context.Context, key string, value interface{}, ttl time.Duration) (bool, error) {
+	if ttl < 0 {
+		return false, nil
+	}
+
+	c.mu.Lock()
+	var evictions []eviction
+	added := c.peek(key) == nil
+	if added {
+		c.set(key, value, ttl, 0, &evictions)
+	}
+	c.mu.Unlock()
+
+	c.evicted(evictions)
+	return added, nil
+}
+
+// GetWithVersion retrieves data given a key along with its version for CompareAndSwap
+func (c *policyCache) GetWithVersion(ctx context.Context, key string) (interface{}, uint64, error) {
+	c.mu.Lock()
+	var evictions []eviction
+	var version uint64
+	value, _, found := c.get(key, &evictions)
+	if found {
+		version = c.items[key].version
+	}
+	c.mu.Unlock()
+
+	c.evicted(evictions)
+
+	if !found {
+		return nil, 0, CacheMissErr
+	}
+	return value, version, nil
+}
+
+// CompareAndSwap replaces the value only if the key still has the version, returning whether it was replaced
+func (c *policyCache) CompareAndSwap(ctx context.Context, key string, version uint64, value interface{}, ttl time.Duration) (bool, error) {
+	c.mu.Lock()
+	var evictions []eviction
+	item := c.peek(key)
+	swapped := item != nil && item.version == version
+	if swapped {
+		c.set(key, value, ttl, 0, &evictions)
+	}
+	c.mu.Unlock()
+
+	c.evicted(evictions)
+	return swapped, nil
+}
+
+// Increment adds delta to the int64 at key and returns the result, a missing key starts at 0 and lives for the ttl
+func (c *policyCache) Increment(ctx context.Context, key string, delta int64, ttl time.Duration) (int64, error) {
+	c.mu.Lock()
+	var evictions []eviction
+	n, err := c.increment(key, delta, ttl, &evictions)
+	c.mu.Unlock()
+
+	c.evicted(evictions)
+	return n, err
+}
+
+// Decrement subtracts delta from the int64 at key, see Increment
+func (c *policyCache) Decrement(ctx context.Context, key string, delta int64, ttl time.Duration) (int64, error) {
+	return c.Increment(ctx, key, -delta, ttl)
+}
+
+// GetAndDelete retrieves data given a key and removes it
+func (c *policyCache) GetAndDelete(ctx context.Context, key string) (interface{}, error) {
+	c.mu.Lock()
+	var evictions []eviction
+	value, _, found := c.get(key, &evictions)
+	if found {
+		c.removeItem(c.items[key])
+	}
+	c.mu.Unlock()
+
+	c.evicted(evictions)
+
+	if !found {
+		return nil, CacheMissErr
+	}
+	return value, nil
+}
+
+// GetMany retrieves data for all the keys, keys that were a cache miss are left out of the result
+func (c *policyCache) GetMany(ctx context.Context, keys []string) (map[string]interface{}, error) {
+	values := make(map[string]interface{}, len(keys))
+
+	c.mu.Lock()
+	var evictions []eviction
+	for _, key := range keys {
+		if value, _, found := c.get(key, &evictions); found {
+			values[key] = value
+		}
+	}
+	c.mu.Unlock()
+
+	c.evicted(evictions)
+	return values, nil
+}
+
+// SetMany adds all the values for their keys, every item lives for the ttl
+func (c *policyCache) SetMany(ctx context.Context, items map[string]interface{}, ttl time.Duration) error {
+	c.mu.Lock()
+	var evictions []eviction
+	for key, value := range items {
+		c.set(key, value, ttl, 0, &evictions)
+	}
+	c.mu.Unlock()
+
+	c.evicted(evictions)
+	return nil
+}
+
+// Delete removes the item from the cache given the key
+func (c *policyCache) Delete(ctx context.Context, key string) error {
+	return c.DeleteMany(ctx, []string{key})
+}
+
+// DeleteMany removes all the items from the cache given the keys
+func (c *policyCache) DeleteMany(ctx context.Context, keys []string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, key := range keys {
+		if item, found := c.items[key]; found {
+			c.removeItem(item)
+		}
+	}
+	return nil
+}
+
+// Purge clear all items in the cache
+func (c *policyCache) Purge(ctx context.Context) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.items = make(map[string]*cacheItem)
+	c.tags = make(tagIndex)
+	c.policy.reset()
+}
+
+// Size returns the number of unexpired items in the cache
+func (c *policyCache) Size(ctx context.Context) uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.size("")
+}
+
+// DeletePrefix removes every item whose key starts with the prefix
+func (c *policyCache) DeletePrefix(ctx context.Context, prefix string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for key, item := range c.items {
+		if strings.HasPrefix(key, prefix) {
+			c.removeItem(item)
+		}
+	}
+	return nil
+}
+
+// SizePrefix returns the number of items whose key starts with the prefix
+func (c *policyCache) SizePrefix(ctx context.Context, prefix string) uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.size(prefix)
+}
+
+// size counts the unexpired items whose key starts with the prefix, must be called while holding the lock
+func (c *policyCache) size(prefix string) uint64 {
+	var size uint64
+	for key, item := range c.items {
+		if strings.HasPrefix(key, prefix) && !item.isExpired() {
+			size++
+		}
+	}
+	return size
+}
+
+// Stats returns a snapshot of the cache's counters
+func (c *policyCache) Stats(ctx context.Context) Stats {
+	stats := c.stats.snapshot()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// expired items still take up room until they are removed, so they count in Bytes but not in Size
+	for _, item := range c.items {
+		if !item.isExpired() {
+			stats.Size++
+		}
+		stats.Bytes += item.weight
+	}
+	return stats
+}
+
+// DeleteExpired removes every expired item from the cache.
+// This is what the janitor runs, but it can be called manually too
+func (c *policyCache) DeleteExpired() {
+	c.mu.Lock()
+	var evictions []eviction
+	for _, item := range c.items {
+		if item.isExpired() {
+			c.removeItem(item)
+			evictions = append(evictions, eviction{item: item, reason: ExpiredEviction})
+		}
+	}
+	c.mu.Unlock()
+
+	c.evicted(evictions)
+}
+
+// Close stops the background janitor if one was started
+func (c *policyCache) Close() error {
+	c.janitor.Stop()
+	return nil
+}
+
+// Save writes every unexpired item to w with the values encoded by the codec
+func (c *policyCache) Save(ctx context.Context, w io.Writer, codec Codec) error {
+	return writeSnapshot(w, codec, c.snapshot())
+}
+
+// Load adds the items saved in r, items that expired in the meantime are skipped and the rest keep their remaining ttl
+func (c *policyCache) Load(ctx context.Context, r io.Reader, codec Codec) error {
+	items, err := readSnapshot(r, codec)
+	if err != nil {
+		return err
+	}
+	c.restore(items)
+	return nil
+}
+
+// getWithTTL retrieves data given a key and how long until it expires
+func (c *policyCache) getWithTTL(ctx context.Context, key string) (interface{}, time.Duration, error) {
+	c.mu.Lock()
+	var evictions []eviction
+	value, _, found := c.get(key, &evictions)
+	var ttl time.Duration
+	if found {
+		ttl = c.peek(key).remaining()
+	}
+	c.mu.Unlock()
+
+	c.evicted(evictions)
+
+	if !found {
+		return nil, 0, CacheMissErr
+	}
+	return value, ttl, nil
+}
+
+// getStale retrieves data given a key and whether the value is past its ttl
+func (c *policyCache) getStale(ctx context.Context, key string) (interface{}, bool, error) {
+	c.mu.Lock()
+	var evictions []eviction
+	value, stale, found := c.get(key, &evictions)
+	c.mu.Unlock()
+
+	c.evicted(evictions)
+
+	if !found {
+		return nil, false, CacheMissErr
+	}
+	return value, stale, nil
+}
+
+// increment must be called while holding the lock, anything evicted to make room for a new key is added to evictions
+func (c *policyCache) increment(key string, delta int64, ttl time.Duration, evictions *[]eviction) (int64, error) {
+	item := c.peek(key)
+	if item == nil {
+		c.set(key, delta, ttl, 0, evictions)
+		return delta, nil
+	}
+
+	n, ok := item.value.(int64)
+	if !ok {
+		return 0, fmt.Errorf("%w: expected int64, got %T", TypeMismatchErr, item.value)
+	}
+
+	// updated in place so the ttl is left alone
+	item.value = n + delta
+	item.version = nextVersion()
+	c.policy.hit(item)
+	c.stats.sets.Add(1)
+	return n + delta, nil
+}
+
+// peek returns the unexpired item for the key without counting a hit, must be called while holding the lock
+func (c *policyCache) peek(key string) *cacheItem {
+	if item, found := c.items[key]; found && !item.isExpired() {
+		return item
+	}
+	return nil
+}
+
+// get must be called while holding the lock, expired items are removed and added to evictions
+func (c *policyCache) get(key string, evictions *[]eviction) (interface{}, bool, bool) {
+	item, found := c.items[key]
+	if !found {
+		c.stats.hit(false)
+		return nil, false, false
+	}
+
+	if item.isExpired() {
+		c.removeItem(item)
+		*evictions = append(*evictions, eviction{item: item, reason: ExpiredEviction})
+		c.stats.hit(false)
+		return nil, false, false
+	}
+
+	c.policy.hit(item)
+	c.stats.hit(true)
+	return item.value, item.isStale(), true
+}
+
+// set must be called while holding the lock, anything evicted to make room is added to evictions
+func (c *policyCache) set(key string, value interface{}, ttl, grace time.Duration, evictions *[]eviction) {
+	// already expired, make sure an older value doesn't stick around
+	if ttl < 0 {
+		if item, found := c.items[key]; found {
+			c.removeItem(item)
+		}
+		return
+	}
+
+	c.add(newCacheItem(key, value, ttl, grace), evictions)
+}
+
+// add must be called while holding the lock, anything evicted to make room is added to evictions
+func (c *policyCache) add(item *cacheItem, evictions *[]eviction) {
+	c.stats.sets.Add(1)
+	item.weight = c.opts.weigh(item.key, item.value)
+
+	var victims []*cacheItem
+	if existing, found := c.items[item.key]; found {
+		// replacing counts as a use of the key, the policy keeps its place
+		oldWeight := existing.weight
+		c.tags.remove(existing)
+		existing.value = item.value
+		existing.expiration = item.expiration
+		existing.softExpiration = item.softExpiration
+		existing.version = item.version
+		existing.tags = item.tags
+		existing.weight = item.weight
+		c.tags.add(existing)
+		victims = c.policy.replace(existing, oldWeight)
+	} else {
+		c.items[item.key] = item
+		c.tags.add(item)
+		victims = c.policy.add(item)
+	}
+
+	for _, victim := range victims {
+		delete(c.items, victim.key)
+		c.tags.remove(victim)
+		*evictions = append(*evictions, eviction{item: victim, reason: CapacityEviction})
+	}
+}
+
+// removeItem must be called while holding the lock
+func (c *policyCache) removeItem(item *cacheItem) {
+	delete(c.items, item.key)
+	c.tags.remove(item)
+	c.policy.remove(item)
+}
+
+// snapshot copies every unexpired item, in the policy's order if it keeps one (LRU).
+// Other state of the policy (e.g. frequencies) isn't kept
+func (c *policyCache) snapshot() []cacheItem {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var all []*cacheItem
+	if op, ok := c.policy.(orderedPolicy); ok {
+		all = op.ordered()
+	} else {
+		for _, item := range c.items {
+			all = append(all, item)
+		}
+	}
+
+	items := make([]cacheItem, 0, len(all))
+	for _, item := range all {
+		if !item.isExpired() {
+			items = append(items, *item)
+		}
+	}
+	return items
+}
+
+// restore adds the items loaded from a snapshot
+func (c *policyCache) restore(items []*cacheItem) {
+	c.mu.Lock()
+	var evictions []eviction
+	for _, item := range items {
+		c.add(item, &evictions)
+	}
+	c.mu.Unlock()
+
+	c.evicted(evictions)
+}
+
+// evicted records and reports items the cache removed, must be called without holding the lock
+func (c *policyCache) evicted(evictions []eviction) {
+	c.stats.evicted(evictions)
+	c.opts.notify(evictions)
+}
@@ -2,24 +2,51 @@ package cache
 
 import (
 	"context"
+	"fmt"
+	"math/rand/v2"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	redis "github.com/redis/go-redis/v9"
 	"github.com/rs/zerolog/log"
 )
 
+// redisCache stores values as plain strings so anything else reading the DB can use them.
+// What the cache needs to know about a key besides its value is kept in a hash next to it, see metaKey
 type redisCache struct {
-	client *redis.Client
+	client redis.UniversalClient
+	loads  flightGroup
+	stats  counters
 }
 
+// NewRedisCache connects to a single redis node
 func NewRedisCache(addr, password string, db int) (*redisCache, error) {
-	client := redis.NewClient(&redis.Options{
-		Addr:     addr,
+	return NewRedisCacheWithOptions(context.Background(), RedisOptions{
+		Addrs:    []string{addr},
 		Password: password,
 		DB:       db,
 	})
+}
+
+// NewRedisCacheWithOptions connects to a single node, cluster or sentinel depending on the options.
+// The connection is checked with the context so a deadline bounds how long connecting can take
+func NewRedisCacheWithOptions(ctx context.Context, opts RedisOptions) (*redisCache, error) {
+	client := opts.newClient()
+
+	rc, err := NewRedisCacheFromClient(ctx, client)
+	if err != nil {
+		client.Close()
+		return nil, err
+	}
+	return rc, nil
+}
 
-	_, err := client.Ping(context.Background()).Result()
+// NewRedisCacheFromClient uses an existing client, for anything the options don't cover
+func NewRedisCacheFromClient(ctx context.Context, client redis.UniversalClient) (*redisCache, error) {
+	_, err := client.Ping(ctx).Result()
 	if err != nil {
 		log.Err(err).Msg("error connecting to redis")
 		return nil, err
@@ -34,42 +61,784 @@ func NewRedisCache(addr, password string, db int) (*redisCache, error) {
 func (rc *redisCache) Get(ctx context.Context, key string) (interface{}, error) {
 	result, err := rc.client.Get(ctx, key).Result()
 	if err == redis.Nil {
+		rc.stats.hit(false)
 		return nil, CacheMissErr
 	} else if err != nil {
 		return nil, err
 	}
 
+	rc.stats.hit(true)
 	return result, nil
 }
 
-// Set adds the value for a given key
+// Set adds the value for a given key, ttl is in seconds
 func (rc *redisCache) Set(ctx context.Context, key string, value interface{}, ttl int) error {
-	return rc.client.Set(ctx, key, value, time.Second*time.Duration(ttl)).Err()
+	return rc.SetTTL(ctx, key, value, seconds(ttl))
+}
+
+// SetTTL adds the value for a given key that lives for the ttl
+func (rc *redisCache) SetTTL(ctx context.Context, key string, value interface{}, ttl time.Duration) error {
+	// go-redis treats negative expirations as KEEPTTL, so an already expired item is just removed
+	if ttl < 0 {
+		return rc.Delete(ctx, key)
+	}
+	_, err := rc.set(ctx, key, value, ttl, 0, false)
+	return err
+}
+
+// SetWithGrace adds the value for a given key that is fresh for the ttl, then stale for the grace period before it expires.
+// The soft expiry is kept in the key's metadata, which expires with it
+func (rc *redisCache) SetWithGrace(ctx context.Context, key string, value interface{}, ttl, grace time.Duration) error {
+	if ttl < 0 {
+		return rc.Delete(ctx, key)
+	}
+
+	var err error
+	if ttl == NoExpiration {
+		_, err = rc.set(ctx, key, value, NoExpiration, 0, false)
+	} else {
+		_, err = rc.set(ctx, key, value, ttl+grace, time.Now().Add(ttl).UnixMilli(), false)
+	}
+	return err
+}
+
+// GetOrLoad retrieves data given a key, calling the loader once on a cache miss
+func (rc *redisCache) GetOrLoad(ctx context.Context, key string, ttl time.Duration, loader LoaderFunc) (interface{}, error) {
+	return getOrLoad(ctx, rc, &rc.loads, key, ttl, loader)
+}
+
+// GetOrRefresh retrieves data given a key, a stale value is returned while the loader refreshes it in the background.
+// Only this process refreshes through its own loads, other instances may refresh the same key at the same time
+func (rc *redisCache) GetOrRefresh(ctx context.Context, key string, ttl, grace time.Duration, loader LoaderFunc) (interface{}, error) {
+	return getOrRefresh(ctx, rc, &rc.loads, key, ttl, grace, loader)
+}
+
+// getWithTTL retrieves data given a key and how long until it expires, from PTTL
+func (rc *redisCache) getWithTTL(ctx context.Context, key string) (interface{}, time.Duration, error) {
+	var get *redis.StringCmd
+	var pttl *redis.DurationCmd
+	_, err := rc.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		get = pipe.Get(ctx, key)
+		pttl = pipe.PTTL(ctx, key)
+		return nil
+	})
+	// -2 is a key that expired between the two commands
+	if err == redis.Nil || pttl.Val() == -2 {
+		rc.stats.hit(false)
+		return nil, 0, CacheMissErr
+	} else if err != nil {
+		return nil, 0, err
+	}
+	rc.stats.hit(true)
+
+	// -1 never expires
+	if pttl.Val() < 0 {
+		return get.Val(), NoExpiration, nil
+	}
+	return get.Val(), pttl.Val(), nil
+}
+
+// getStale retrieves data given a key and whether the value is past its ttl.
+// Values without a soft expiry (not set with SetWithGrace) are never stale
+func (rc *redisCache) getStale(ctx context.Context, key string) (interface{}, bool, error) {
+	fields, err := getStaleScript.Run(ctx, rc.client, itemKeys(key)).Slice()
+	if err == redis.Nil {
+		rc.stats.hit(false)
+		return nil, false, CacheMissErr
+	} else if err != nil {
+		return nil, false, err
+	}
+	rc.stats.hit(true)
+
+	softExpiration, _ := fields[1].(string)
+	staleAt, err := strconv.ParseInt(softExpiration, 10, 64)
+	if err != nil {
+		return fields[0], false, nil
+	}
+	return fields[0], time.Now().UnixMilli() >= staleAt, nil
 }
 
 // Delete removes the item from the cache given the key
 func (rc *redisCache) Delete(ctx context.Context, key string) error {
-	_, err := rc.client.Del(ctx, key).Result()
+	oldTags, err := deleteScript.Run(ctx, rc.client, itemKeys(key)).Text()
+	if err != nil {
+		return err
+	}
+	return rc.retag(ctx, map[string]string{key: oldTags}, 0)
+}
+
+// SetWithTags adds the value for a given key that lives for the ttl, the item can be invalidated by any of its tags.
+// Each tag is a set of keys that lives as long as its longest lived key, the key's metadata keeps the tags it has now
+// so setting it again replaces them. Tags can't contain newlines
+func (rc *redisCache) SetWithTags(ctx context.Context, key string, value interface{}, ttl time.Duration, tags ...string) error {
+	if ttl < 0 {
+		return rc.Delete(ctx, key)
+	}
+
+	_, err := rc.set(ctx, key, value, ttl, 0, false, tags...)
+	return err
+}
+
+// InvalidateTags removes every key that was set with any of the tags along with the tags themselves.
+// Keys that were set again without the tag since are left alone
+func (rc *redisCache) InvalidateTags(ctx context.Context, tags ...string) error {
+	if len(tags) == 0 {
+		return nil
+	}
+
+	members := make([]*redis.StringSliceCmd, len(tags))
+	_, err := rc.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for i, tag := range tags {
+			members[i] = pipe.SMembers(ctx, tagKey(tag))
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	removed := make(map[string]*redis.Cmd)
+	_, err = rc.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for i, tag := range tags {
+			for _, key := range members[i].Val() {
+				// Eval instead of Run since a NOSCRIPT error isn't known until the pipeline runs
+				removed[key+"\n"+tag] = invalidateScript.Eval(ctx, pipe, itemKeys(key), tag)
+			}
+			pipe.Unlink(ctx, tagKey(tag))
+		}
+		return nil
+	})
+	if err != nil && err != redis.Nil {
+		return err
+	}
+
+	oldTags := make(map[string]string, len(removed))
+	for id, cmd := range removed {
+		if tags, err := cmd.Text(); err == nil {
+			key, _, _ := strings.Cut(id, "\n")
+			oldTags[key] = tags
+		}
+	}
+	return rc.retag(ctx, oldTags, 0)
+}
+
+// SetNX adds the value only if the key doesn't exist, returning whether it was added
+func (rc *redisCache) SetNX(ctx context.Context, key string, value interface{}, ttl time.Duration) (bool, error) {
+	// go-redis treats negative expirations as KEEPTTL, an already expired item is never added
+	if ttl < 0 {
+		return false, nil
+	}
+
+	return rc.set(ctx, key, value, ttl, 0, true)
+}
+
+// GetWithVersion retrieves data given a key along with its version for CompareAndSwap.
+// The version is a counter in the key's metadata that every write through the cache bumps,
+// so writing the same value again still changes it. A key without one gets it here
+func (rc *redisCache) GetWithVersion(ctx context.Context, key string) (interface{}, uint64, error) {
+	fields, err := versionScript.Run(ctx, rc.client, itemKeys(key), newVersion()).Slice()
+	if err == redis.Nil {
+		rc.stats.hit(false)
+		return nil, 0, CacheMissErr
+	} else if err != nil {
+		return nil, 0, err
+	}
+
+	rc.stats.hit(true)
+	version, _ := fields[1].(string)
+	n, _ := strconv.ParseUint(version, 10, 64)
+	return fields[0], n, nil
+}
+
+// CompareAndSwap replaces the value only if the key still has the version, returning whether it was replaced
+func (rc *redisCache) CompareAndSwap(ctx context.Context, key string, version uint64, value interface{}, ttl time.Duration) (bool, error) {
+	oldTags, err := casScript.Run(ctx, rc.client, itemKeys(key), version, value, ttl.Milliseconds(), newVersion()).Text()
+	if err == redis.Nil {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	rc.stats.sets.Add(1)
+	return true, rc.retag(ctx, map[string]string{key: oldTags}, 0)
+}
+
+// Increment adds delta to the integer at key and returns the result, a missing key starts at 0 and lives for the ttl
+func (rc *redisCache) Increment(ctx context.Context, key string, delta int64, ttl time.Duration) (int64, error) {
+	n, err := incrScript.Run(ctx, rc.client, itemKeys(key), delta, ttl.Milliseconds()).Int64()
+	if err != nil {
+		if strings.Contains(err.Error(), "not an integer") {
+			return 0, fmt.Errorf("%w: %v", TypeMismatchErr, err)
+		}
+		return 0, err
+	}
+	rc.stats.sets.Add(1)
+	return n, nil
+}
+
+// Decrement subtracts delta from the integer at key, see Increment
+func (rc *redisCache) Decrement(ctx context.Context, key string, delta int64, ttl time.Duration) (int64, error) {
+	return rc.Increment(ctx, key, -delta, ttl)
+}
+
+// GetAndDelete retrieves data given a key and removes it in one script
+func (rc *redisCache) GetAndDelete(ctx context.Context, key string) (interface{}, error) {
+	result, err := getDelScript.Run(ctx, rc.client, itemKeys(key)).StringSlice()
+	if err == redis.Nil {
+		rc.stats.hit(false)
+		return nil, CacheMissErr
+	} else if err != nil {
+		return nil, err
+	}
+
+	rc.stats.hit(true)
+	return result[0], rc.retag(ctx, map[string]string{key: result[1]}, 0)
+}
+
+// GetMany retrieves data for all the keys in a single pipeline, keys that were a cache miss are left out of the result
+func (rc *redisCache) GetMany(ctx context.Context, keys []string) (map[string]interface{}, error) {
+	values := make(map[string]interface{}, len(keys))
+	if len(keys) == 0 {
+		return values, nil
+	}
+
+	results, err := rc.mget(ctx, keys)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, result := range results {
+		rc.stats.hit(result != nil)
+		if result != nil {
+			values[keys[i]] = result
+		}
+	}
+	return values, nil
+}
+
+// SetMany adds all the values for their keys in a single pipeline, every item lives for the ttl
+func (rc *redisCache) SetMany(ctx context.Context, items map[string]interface{}, ttl time.Duration) error {
+	if len(items) == 0 {
+		return nil
+	}
+
+	if ttl < 0 {
+		keys := make([]string, 0, len(items))
+		for key := range items {
+			keys = append(keys, key)
+		}
+		return rc.DeleteMany(ctx, keys)
+	}
+
+	cmds := make(map[string]*redis.Cmd, len(items))
+	_, err := rc.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for key, value := range items {
+			cmds[key] = setScript.Eval(ctx, pipe, itemKeys(key), setArgs(value, ttl, 0, false)...)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	rc.stats.sets.Add(uint64(len(items)))
+	return rc.retag(ctx, results(cmds), 0)
+}
+
+// DeleteMany removes all the items from the cache given the keys
+func (rc *redisCache) DeleteMany(ctx context.Context, keys []string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+
+	return rc.deleteKeys(ctx, rc.client, keys)
+}
+
+// deleteKeys removes the keys one at a time in a pipeline since they can be in different cluster slots
+func (rc *redisCache) deleteKeys(ctx context.Context, client redis.Cmdable, keys []string) error {
+	cmds := make(map[string]*redis.Cmd, len(keys))
+	_, err := client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for _, key := range keys {
+			cmds[key] = deleteScript.Eval(ctx, pipe, itemKeys(key))
+		}
+		return nil
+	})
 	if err != nil {
 		return err
 	}
-	return nil
+	return rc.retag(ctx, results(cmds), 0)
 }
 
 // Purge clear all items in the cache
 func (rc *redisCache) Purge(ctx context.Context) {
-	err := rc.client.FlushDB(ctx).Err()
+	err := rc.forEachNode(ctx, func(ctx context.Context, client *redis.Client) error {
+		return client.FlushDB(ctx).Err()
+	})
 	if err != nil {
 		log.Err(err).Msg("failed to flush redis DB")
 	}
 }
 
-// Size returns the number of elements in the cache
+// Size returns the number of elements in the cache using SCAN, the keys' metadata and tag sets aren't counted
 func (rc *redisCache) Size(ctx context.Context) uint64 {
-	size, err := rc.client.DBSize(ctx).Result()
+	size, err := rc.count(ctx, "*")
 	if err != nil {
 		log.Err(err).Msg("failed to return size of redis cache")
 		return 0
 	}
-	return uint64(size)
+	return size
+}
+
+// DeletePrefix removes every key that starts with the prefix using SCAN, so other keys in the DB are left alone
+func (rc *redisCache) DeletePrefix(ctx context.Context, prefix string) error {
+	return rc.DeletePattern(ctx, escapeGlob(prefix)+"*")
+}
+
+// DeletePattern removes every key matching the glob pattern (e.g. "user:42:*") using SCAN.
+// The pattern uses the same syntax as the KEYS command
+func (rc *redisCache) DeletePattern(ctx context.Context, pattern string) error {
+	return rc.scan(ctx, pattern, func(ctx context.Context, client *redis.Client, keys []string) error {
+		return rc.deleteKeys(ctx, client, keys)
+	})
+}
+
+// SizePrefix returns the number of keys that start with the prefix using SCAN
+func (rc *redisCache) SizePrefix(ctx context.Context, prefix string) uint64 {
+	size, err := rc.count(ctx, escapeGlob(prefix)+"*")
+	if err != nil {
+		log.Err(err).Str("prefix", prefix).Msg("failed to return size of redis prefix")
+		return 0
+	}
+	return size
+}
+
+// count returns the number of keys matching the glob pattern that are cache items
+func (rc *redisCache) count(ctx context.Context, match string) (uint64, error) {
+	var mu sync.Mutex
+	seen := make(map[string]struct{})
+
+	// SCAN can return the same key more than once
+	err := rc.scan(ctx, match, func(ctx context.Context, client *redis.Client, keys []string) error {
+		mu.Lock()
+		defer mu.Unlock()
+		for _, key := range keys {
+			if !internalKey(key) {
+				seen[key] = struct{}{}
+			}
+		}
+		return nil
+	})
+	return uint64(len(seen)), err
+}
+
+// Client returns the underlying redis client for anything the cache doesn't cover
+func (rc *redisCache) Client() redis.UniversalClient {
+	return rc.client
+}
+
+// Close closes the connection to redis
+func (rc *redisCache) Close() error {
+	return rc.client.Close()
+}
+
+// mget returns the value of every key, nil for keys that don't exist.
+// The cluster client splits the pipeline up by node
+func (rc *redisCache) mget(ctx context.Context, keys []string) ([]interface{}, error) {
+	cmds := make([]*redis.StringCmd, len(keys))
+	_, err := rc.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for i, key := range keys {
+			cmds[i] = pipe.Get(ctx, key)
+		}
+		return nil
+	})
+	if err != nil && err != redis.Nil {
+		return nil, err
+	}
+
+	results := make([]interface{}, len(keys))
+	for i, cmd := range cmds {
+		if value, err := cmd.Result(); err == nil {
+			results[i] = value
+		}
+	}
+	return results, nil
+}
+
+// set runs setScript and moves the key to the sets of its new tags, returning whether it was written
+func (rc *redisCache) set(ctx context.Context, key string, value interface{}, ttl time.Duration, softExpiration int64, nx bool, tags ...string) (bool, error) {
+	oldTags, err := setScript.Run(ctx, rc.client, itemKeys(key), setArgs(value, ttl, softExpiration, nx, tags...)...).Text()
+	if err == redis.Nil {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	rc.stats.sets.Add(1)
+	return true, rc.retag(ctx, map[string]string{key: oldTags}, ttl, tags...)
+}
+
+// retag takes the keys out of the sets of their old tags, separated by newlines the way the scripts return them,
+// and adds them to the sets of the new tags. The sets can be in other cluster slots than the keys so the scripts
+// can't do it. Until it is done InvalidateTags may still find a key in an old set, it skips keys without the tag
+func (rc *redisCache) retag(ctx context.Context, oldTags map[string]string, ttl time.Duration, tags ...string) error {
+	queued := len(tags) > 0
+	for _, old := range oldTags {
+		queued = queued || old != ""
+	}
+	if !queued {
+		return nil
+	}
+
+	_, err := rc.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for key, old := range oldTags {
+			for _, tag := range tags {
+				tagScript.Eval(ctx, pipe, []string{tagKey(tag)}, key, ttl.Milliseconds())
+			}
+			for _, tag := range strings.Split(old, "\n") {
+				if tag != "" && !slices.Contains(tags, tag) {
+					pipe.SRem(ctx, tagKey(tag), key)
+				}
+			}
+		}
+		return nil
+	})
+	return err
+}
+
+// results returns the text of the scripts' results by key, leaving out the ones that returned nothing
+func results(cmds map[string]*redis.Cmd) map[string]string {
+	texts := make(map[string]string, len(cmds))
+	for key, cmd := range cmds {
+		if text, err := cmd.Text(); err == nil {
+			texts[key] = text
+		}
+	}
+	return texts
+}
+
+// metaKeyPrefix names the hashes with what the cache knows about a key besides its value.
+// Its fields are "ver", the version every write bumps, "soft", the unix milliseconds after which a value set with
+// SetWithGrace is stale, and "tags", the key's tags separated by newlines. It expires with the key and is only
+// created when one of them is needed, a key that is only set and read is a plain string
+const metaKeyPrefix = "cache-meta:"
+
+// metaKey is the name of the key's metadata hash. It has the same hash tag as the key so both are in the same
+// cluster slot, which isn't possible for keys with a } outside of a hash tag
+func metaKey(key string) string {
+	if start := strings.IndexByte(key, '{'); start >= 0 {
+		if end := strings.IndexByte(key[start+1:], '}'); end > 0 {
+			return metaKeyPrefix + key
+		}
+	}
+	if !strings.Contains(key, "}") {
+		return metaKeyPrefix + "{" + key + "}"
+	}
+	return metaKeyPrefix + key
+}
+
+// itemKeys are the KEYS of the scripts working on a key, the key and its metadata
+func itemKeys(key string) []string {
+	return []string{key, metaKey(key)}
+}
+
+// internalKey is true for the keys the cache keeps for itself rather than items
+func internalKey(key string) bool {
+	return strings.HasPrefix(key, metaKeyPrefix) || strings.HasPrefix(key, tagKeyPrefix)
+}
+
+// tagLua defines tag, which adds the key to the tag's set and makes sure the set lives at least as long as the key.
+// The ttl is in milliseconds, 0 never expires
+const tagLua = `
+local function tag(set, key, ttl)
+	local existed = redis.call('EXISTS', set)
+	redis.call('SADD', set, key)
+
+	if ttl == 0 then
+		redis.call('PERSIST', set)
+		return
+	end
+
+	local current = redis.call('PTTL', set)
+	if existed == 0 or (current >= 0 and current < ttl) then
+		redis.call('PEXPIRE', set, ttl)
+	end
+end
+`
+
+// writeLua defines write for the scripts that set an item, it replaces the value and the soft expiry and tags but bumps the version.
+// The ttl is in milliseconds and 0 never expires, soft is the soft expiry or empty if the value is never stale.
+// seed is the version of new metadata. HINCRBY keeps the version an integer, Lua would format big numbers as floats.
+// Returns the key's old tags, the caller takes it out of their sets, see retag
+const writeLua = `
+local function write(key, meta, value, ttl, soft, seed, tags)
+	if ttl > 0 then
+		redis.call('SET', key, value, 'PX', ttl)
+	else
+		redis.call('SET', key, value)
+	end
+
+	local old = redis.call('HGET', meta, 'tags') or ''
+	local existed = redis.call('EXISTS', meta) == 1
+	if not existed and soft == '' and #tags == 0 then
+		return old
+	end
+	if existed then
+		redis.call('HDEL', meta, 'soft', 'tags')
+		redis.call('HINCRBY', meta, 'ver', 1)
+	else
+		redis.call('HSET', meta, 'ver', seed)
+	end
+	if soft ~= '' then
+		redis.call('HSET', meta, 'soft', soft)
+	end
+	if #tags > 0 then
+		redis.call('HSET', meta, 'tags', table.concat(tags, '\n'))
+	end
+	if ttl > 0 then
+		redis.call('PEXPIRE', meta, ttl)
+	else
+		redis.call('PERSIST', meta)
+	end
+	return old
+end
+`
+
+// setScript sets KEYS[1] to ARGV[1] and updates its metadata (KEYS[2]), ARGV[2] is the ttl in milliseconds and ARGV[3]
+// the soft expiry or empty. With ARGV[4] set to 'nx' nothing is written if the key exists. ARGV[5] is the version seed
+// and the rest are the tags. Returns the old tags, or nil if nothing was written
+var setScript = redis.NewScript(writeLua + `
+if ARGV[4] == 'nx' and redis.call('EXISTS', KEYS[1]) == 1 then
+	return false
+end
+return write(KEYS[1], KEYS[2], ARGV[1], tonumber(ARGV[2]), ARGV[3], ARGV[5], {unpack(ARGV, 6)})
+`)
+
+// setArgs are the ARGV for setScript, a softExpiration of 0 is never stale
+func setArgs(value interface{}, ttl time.Duration, softExpiration int64, nx bool, tags ...string) []interface{} {
+	soft := ""
+	if softExpiration > 0 {
+		soft = strconv.FormatInt(softExpiration, 10)
+	}
+	mode := ""
+	if nx {
+		mode = "nx"
+	}
+	args := []interface{}{value, ttl.Milliseconds(), soft, mode, newVersion()}
+	for _, tag := range tags {
+		args = append(args, tag)
+	}
+	return args
+}
+
+// newVersion is the version of a key that gets metadata. It is random rather than 1 so a key that is deleted
+// and set again doesn't start over at a version someone may still hold. Below 2^52 so Lua's numbers stay exact
+func newVersion() uint64 {
+	return rand.Uint64N(1<<52) + 1
+}
+
+// getStaleScript returns the value of KEYS[1] and its soft expiry from KEYS[2], nil if the key doesn't exist
+var getStaleScript = redis.NewScript(`
+local value = redis.call('GET', KEYS[1])
+if not value then
+	return false
+end
+return {value, redis.call('HGET', KEYS[2], 'soft')}
+`)
+
+// versionScript returns the value of KEYS[1] and its version from KEYS[2], nil if the key doesn't exist.
+// A key without a version gets ARGV[1], its metadata expires with it
+var versionScript = redis.NewScript(`
+local value = redis.call('GET', KEYS[1])
+if not value then
+	return false
+end
+
+local version = redis.call('HGET', KEYS[2], 'ver')
+if not version then
+	version = ARGV[1]
+	redis.call('HSET', KEYS[2], 'ver', version)
+	local ttl = redis.call('PTTL', KEYS[1])
+	if ttl > 0 then
+		redis.call('PEXPIRE', KEYS[2], ttl)
+	end
+end
+return {value, version}
+`)
+
+// getDelScript returns the value of KEYS[1] and its tags and removes it with its metadata, nil if it doesn't exist
+var getDelScript = redis.NewScript(`
+local value = redis.call('GET', KEYS[1])
+if not value then
+	return false
+end
+local tags = redis.call('HGET', KEYS[2], 'tags') or ''
+redis.call('DEL', KEYS[1], KEYS[2])
+return {value, tags}
+`)
+
+// deleteScript removes KEYS[1] and its metadata, returning its tags
+var deleteScript = redis.NewScript(`
+local tags = redis.call('HGET', KEYS[2], 'tags') or ''
+redis.call('DEL', KEYS[1], KEYS[2])
+return tags
+`)
+
+// invalidateScript removes KEYS[1] if it still has the tag ARGV[1], returning its tags or nil if it was left alone.
+// A key stays in a tag's set after it expires and until retag took it out after it was set again
+var invalidateScript = redis.NewScript(`
+if redis.call('EXISTS', KEYS[1]) == 0 then
+	return false
+end
+local tags = redis.call('HGET', KEYS[2], 'tags')
+if not tags or not string.find('\n' .. tags .. '\n', '\n' .. ARGV[1] .. '\n', 1, true) then
+	return false
+end
+redis.call('DEL', KEYS[1], KEYS[2])
+return tags
+`)
+
+// casScript sets KEYS[1] to ARGV[2] only if its version is still ARGV[1], returning its old tags or nil if it wasn't swapped.
+// ARGV[3] is the ttl in milliseconds, 0 never expires and a negative ttl removes the key. ARGV[4] is the version seed,
+// the new value has no tags
+var casScript = redis.NewScript(writeLua + `
+local current = redis.call('HGET', KEYS[2], 'ver')
+if not current or current ~= ARGV[1] or redis.call('EXISTS', KEYS[1]) == 0 then
+	return false
+end
+
+local ttl = tonumber(ARGV[3])
+if ttl < 0 then
+	local tags = redis.call('HGET', KEYS[2], 'tags') or ''
+	redis.call('DEL', KEYS[1], KEYS[2])
+	return tags
+end
+return write(KEYS[1], KEYS[2], ARGV[2], ttl, '', ARGV[4], {})
+`)
+
+// incrScript adds ARGV[1] to KEYS[1] and bumps its version if it has one, the ttl in milliseconds (ARGV[2])
+// is only set when the key is created. Metadata left behind by a key that no longer exists is dropped
+var incrScript = redis.NewScript(`
+local existed = redis.call('EXISTS', KEYS[1])
+local value = redis.call('INCRBY', KEYS[1], ARGV[1])
+if existed == 1 then
+	if redis.call('EXISTS', KEYS[2]) == 1 then
+		redis.call('HINCRBY', KEYS[2], 'ver', 1)
+	end
+	return value
+end
+
+redis.call('DEL', KEYS[2])
+local ttl = tonumber(ARGV[2])
+if ttl > 0 then
+	redis.call('PEXPIRE', KEYS[1], ttl)
+elseif ttl < 0 then
+	redis.call('DEL', KEYS[1])
+end
+return value
+`)
+
+// tagKeyPrefix names the sets of keys for each tag
+const tagKeyPrefix = "cache-tag:"
+
+func tagKey(tag string) string {
+	return tagKeyPrefix + tag
+}
+
+// tagScript adds the key (ARGV[1]) to the tag set (KEYS[1]), see retag.
+// ARGV[2] is the key's ttl in milliseconds, 0 never expires
+var tagScript = redis.NewScript(tagLua + `
+tag(KEYS[1], ARGV[1], tonumber(ARGV[2]))
+return 1
+`)
+
+// scanCount is how many keys SCAN looks at per call
+const scanCount = 1000
+
+// scan calls fn with every batch of keys matching the glob pattern on every node
+func (rc *redisCache) scan(ctx context.Context, match string, fn func(ctx context.Context, client *redis.Client, keys []string) error) error {
+	return rc.forEachNode(ctx, func(ctx context.Context, client *redis.Client) error {
+		var cursor uint64
+		for {
+			keys, next, err := client.Scan(ctx, cursor, match, scanCount).Result()
+			if err != nil {
+				return err
+			}
+
+			if len(keys) > 0 {
+				if err := fn(ctx, client, keys); err != nil {
+					return err
+				}
+			}
+
+			if next == 0 {
+				return nil
+			}
+			cursor = next
+		}
+	})
+}
+
+var globEscaper = strings.NewReplacer(`\`, `\\`, `*`, `\*`, `?`, `\?`, `[`, `\[`, `]`, `\]`)
+
+// escapeGlob escapes the characters redis treats as special in MATCH patterns
+func escapeGlob(s string) string {
+	return globEscaper.Replace(s)
+}
+
+// forEachNode runs fn against every master in cluster mode, otherwise just the one node
+func (rc *redisCache) forEachNode(ctx context.Context, fn func(ctx context.Context, client *redis.Client) error) error {
+	switch client := rc.client.(type) {
+	case *redis.ClusterClient:
+		return client.ForEachMaster(ctx, fn)
+	case *redis.Ring:
+		return client.ForEachShard(ctx, fn)
+	case *redis.Client:
+		return fn(ctx, client)
+	default:
+		return fmt.Errorf("unsupported redis client %T", rc.client)
+	}
+}
+
+// redis can only store strings/bytes so values need to be encoded
+func (rc *redisCache) requiresEncoding() bool {
+	return true
+}
+
+// Stats returns a snapshot of the cache's counters. Hits, misses and sets are counted by this client,
+// evictions, expirations and bytes come from the redis server and include every client
+func (rc *redisCache) Stats(ctx context.Context) Stats {
+	stats := rc.stats.snapshot()
+	stats.Size = rc.Size(ctx)
+
+	var mu sync.Mutex
+	err := rc.forEachNode(ctx, func(ctx context.Context, client *redis.Client) error {
+		info, err := client.Info(ctx, "stats", "memory").Result()
+		if err != nil {
+			return err
+		}
+
+		fields := parseInfo(info)
+		mu.Lock()
+		defer mu.Unlock()
+		stats.Evictions += fields["evicted_keys"]
+		stats.Expirations += fields["expired_keys"]
+		stats.Bytes += fields["used_memory"]
+		return nil
+	})
+	if err != nil {
+		log.Err(err).Msg("failed to get redis info")
+	}
+	return stats
+}
+
+// parseInfo parses the numeric fields of the INFO command's "field:value" lines
+func parseInfo(info string) map[string]uint64 {
+	fields := make(map[string]uint64)
+	for _, line := range strings.Split(info, "\n") {
+		name, value, found := strings.Cut(strings.TrimSpace(line), ":")
+		if !found {
+			continue
+		}
+		if n, err := strconv.ParseUint(value, 10, 64); err == nil {
+			fields[name] = n
+		}
+	}
+	return fields
 }
@@ -0,0 +1,47 @@
+package cache
+
+import (
+	"context"
+
+	"github.com/rs/zerolog/log"
+)
+
+// redisInvalidationBus broadcasts invalidations using redis pub/sub
+type redisInvalidationBus struct {
+	cache   *redisCache
+	channel string
+}
+
+// NewRedisInvalidationBus creates an InvalidationBus that publishes to the redis channel
+func NewRedisInvalidationBus(rc *redisCache, channel string) *redisInvalidationBus {
+	return &redisInvalidationBus{
+		cache:   rc,
+		channel: channel,
+	}
+}
+
+// Publish sends the message to every subscriber of the channel
+func (b *redisInvalidationBus) Publish(ctx context.Context, message []byte) error {
+	return b.cache.client.Publish(ctx, b.channel, message).Err()
+}
+
+// Subscribe calls the handler for every message on the channel until the returned function is called
+func (b *redisInvalidationBus) Subscribe(ctx context.Context, handler func(message []byte)) (func() error, error) {
+	pubsub := b.cache.client.Subscribe(ctx, b.channel)
+
+	// wait for the subscription to be confirmed so no messages are missed after we return
+	if _, err := pubsub.Receive(ctx); err != nil {
+		pubsub.Close()
+		log.Err(err).Str("channel", b.channel).Msg("failed to subscribe to redis channel")
+		return nil, err
+	}
+
+	go func() {
+		// the channel is closed when pubsub is closed
+		for msg := range pubsub.Channel() {
+			handler([]byte(msg.Payload))
+		}
+	}()
+
+	return pubsub.Close, nil
+}
@@ -0,0 +1,67 @@
+package cache
+
+import (
+	"crypto/tls"
+	"time"
+
+	redis "github.com/redis/go-redis/v9"
+)
+
+// RedisOptions configures the connection to redis.
+// A single address connects to one node, more than one (or ClusterMode) connects to a cluster
+// and setting MasterName connects through sentinel, in which case Addrs are the sentinels
+type RedisOptions struct {
+	Addrs       []string
+	ClusterMode bool
+	MasterName  string
+
+	Username         string
+	Password         string
+	SentinelUsername string
+	SentinelPassword string
+	DB               int // ignored in cluster mode
+
+	// TLSConfig enables TLS when set
+	TLSConfig *tls.Config
+
+	PoolSize        int
+	MinIdleConns    int
+	PoolTimeout     time.Duration
+	ConnMaxIdleTime time.Duration
+
+	DialTimeout  time.Duration
+	ReadTimeout  time.Duration
+	WriteTimeout time.Duration
+
+	MaxRetries int
+}
+
+func (o RedisOptions) universal() *redis.UniversalOptions {
+	return &redis.UniversalOptions{
+		Addrs:            o.Addrs,
+		MasterName:       o.MasterName,
+		Username:         o.Username,
+		Password:         o.Password,
+		SentinelUsername: o.SentinelUsername,
+		SentinelPassword: o.SentinelPassword,
+		DB:               o.DB,
+		TLSConfig:        o.TLSConfig,
+		PoolSize:         o.PoolSize,
+		MinIdleConns:     o.MinIdleConns,
+		PoolTimeout:      o.PoolTimeout,
+		ConnMaxIdleTime:  o.ConnMaxIdleTime,
+		DialTimeout:      o.DialTimeout,
+		ReadTimeout:      o.ReadTimeout,
+		WriteTimeout:     o.WriteTimeout,
+		MaxRetries:       o.MaxRetries,
+	}
+}
+
+// newClient picks the right client for the options
+func (o RedisOptions) newClient() redis.UniversalClient {
+	universal := o.universal()
+	if o.ClusterMode && o.MasterName == "" {
+		return redis.NewClusterClient(universal.Cluster())
+	}
+	return redis.NewUniversalClient(universal)
+}
@@ -0,0 +1,523 @@
+package cache
+
+import (
+	"context"
+	"crypto/tls"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	redis "github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+// RedisTestSuite runs the redis cache against miniredis, which runs the Lua scripts too
+type RedisTestSuite struct {
+	suite.Suite
+	server *miniredis.Miniredis
+	cache  *redisCache
+}
+
+func TestRedisSuite(t *testing.T) {
+	suite.Run(t, new(RedisTestSuite))
+}
+
+// SetupTest runs before each test in the suite
+func (s *RedisTestSuite) SetupTest() {
+	s.server = miniredis.RunT(s.T())
+
+	rc, err := NewRedisCacheFromClient(context.Background(), redis.NewClient(&redis.Options{Addr: s.server.Addr()}))
+	s.Require().NoError(err)
+	s.cache = rc
+}
+
+// TearDownTest runs after each test in the suite
+func (s *RedisTestSuite) TearDownTest() {
+	s.cache.Close()
+}
+
+func (s *RedisTestSuite) TestSetWithGrace_Stale() {
+	ctx := context.Background()
+	s.NoError(s.cache.SetWithGrace(ctx, "key", "value", 10*time.Millisecond, time.Minute))
+
+	value, stale, err := s.cache.getStale(ctx, "key")
+	s.NoError(err)
+	s.Equal("value", value)
+	s.False(stale)
+
+	time.Sleep(20 * time.Millisecond)
+	value, stale, err = s.cache.getStale(ctx, "key")
+	s.NoError(err)
+	s.Equal("value", value)
+	s.True(stale)
+
+	// kept for the ttl plus the grace period
+	s.Equal(time.Minute+10*time.Millisecond, s.server.TTL("key"))
+}
+
+func (s *RedisTestSuite) TestSetWithGrace_ReplacedBySetTTL() {
+	ctx := context.Background()
+	s.NoError(s.cache.SetWithGrace(ctx, "key", "old", time.Millisecond, time.Minute))
+	time.Sleep(5 * time.Millisecond)
+	s.NoError(s.cache.SetTTL(ctx, "key", "new", time.Minute))
+
+	value, stale, err := s.cache.getStale(ctx, "key")
+	s.NoError(err)
+	s.Equal("new", value)
+	s.False(stale)
+
+	value, err = s.cache.GetOrRefresh(ctx, "key", time.Minute, time.Minute, func(ctx context.Context, key string) (interface{}, error) {
+		s.Fail("loader should not be called while the value is fresh")
+		return nil, nil
+	})
+	s.NoError(err)
+	s.Equal("new", value)
+}
+
+func (s *RedisTestSuite) TestGetWithTTL() {
+	ctx := context.Background()
+	s.NoError(s.cache.SetTTL(ctx, "expiring", "value", time.Minute))
+	s.NoError(s.cache.SetTTL(ctx, "forever", "value", NoExpiration))
+
+	value, ttl, err := s.cache.getWithTTL(ctx, "expiring")
+	s.NoError(err)
+	s.Equal("value", value)
+	s.Equal(time.Minute, ttl)
+
+	_, ttl, err = s.cache.getWithTTL(ctx, "forever")
+	s.NoError(err)
+	s.Equal(NoExpiration, ttl)
+
+	_, _, err = s.cache.getWithTTL(ctx, "does_not_exist")
+	s.ErrorIs(err, CacheMissErr)
+}
+
+func (s *RedisTestSuite) TestSetWithGrace_PlainValue() {
+	ctx := context.Background()
+	s.NoError(s.cache.SetWithGrace(ctx, "key", "value", time.Minute, time.Minute))
+
+	// other clients still read a plain string, the soft expiry is in the metadata which isn't an item
+	value, err := s.server.Get("key")
+	s.NoError(err)
+	s.Equal("value", value)
+	s.True(s.server.Exists(metaKey("key")))
+	s.Equal(uint64(1), s.cache.Size(ctx))
+	s.Equal(uint64(1), s.cache.SizePrefix(ctx, ""))
+
+	s.NoError(s.cache.Delete(ctx, "key"))
+	s.Empty(s.server.Keys())
+}
+
+func (s *RedisTestSuite) TestPlainStrings() {
+	ctx := context.Background()
+	// written by something else
+	s.NoError(s.server.Set("other", "value"))
+
+	value, err := s.cache.GetOrLoad(ctx, "other", time.Minute, func(ctx context.Context, key string) (interface{}, error) {
+		s.Fail("loader should not be called on a hit")
+		return nil, nil
+	})
+	s.NoError(err)
+	s.Equal("value", value)
+
+	// keys that are only set and read get no metadata
+	s.NoError(s.cache.SetTTL(ctx, "key", "value", time.Minute))
+	s.NoError(s.cache.SetMany(ctx, map[string]interface{}{"many": "value"}, time.Minute))
+	s.ElementsMatch([]string{"other", "key", "many"}, s.server.Keys())
+
+	_, version, err := s.cache.GetWithVersion(ctx, "other")
+	s.NoError(err)
+	swapped, err := s.cache.CompareAndSwap(ctx, "other", version, "swapped", time.Minute)
+	s.NoError(err)
+	s.True(swapped)
+
+	value, err = s.server.Get("other")
+	s.NoError(err)
+	s.Equal("swapped", value)
+	s.Equal(time.Minute, s.server.TTL(metaKey("other")))
+}
+
+func TestMetaKey(t *testing.T) {
+	tests := []struct {
+		key      string
+		expected string
+	}{
+		{key: "user:1", expected: "cache-meta:{user:1}"},
+		{key: "user:{42}:orders", expected: "cache-meta:user:{42}:orders"},
+		{key: "user:{42", expected: "cache-meta:{user:{42}"},
+		// no hash tag to share, the key is hashed whole and the } can't be in one
+		{key: "user:{}:1", expected: "cache-meta:user:{}:1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			assert.Equal(t, tt.expected, metaKey(tt.key))
+		})
+	}
+}
+
+func (s *RedisTestSuite) TestGetOrRefresh_Stale() {
+	ctx := context.Background()
+	s.NoError(s.cache.SetWithGrace(ctx, "key", "stale", time.Millisecond, time.Minute))
+	time.Sleep(5 * time.Millisecond)
+
+	refreshed := make(chan struct{})
+	value, err := s.cache.GetOrRefresh(ctx, "key", time.Minute, time.Minute, func(ctx context.Context, key string) (interface{}, error) {
+		defer close(refreshed)
+		return "fresh", nil
+	})
+	s.NoError(err)
+	s.Equal("stale", value)
+
+	<-refreshed
+	s.Eventually(func() bool {
+		value, stale, err := s.cache.getStale(ctx, "key")
+		return err == nil && value == "fresh" && !stale
+	}, time.Second, time.Millisecond)
+}
+
+func (s *RedisTestSuite) TestSetTTL() {
+	ctx := context.Background()
+	s.NoError(s.cache.SetTTL(ctx, "key", "value", time.Minute))
+	s.NoError(s.cache.SetTTL(ctx, "forever", 42, NoExpiration))
+
+	value, err := s.cache.Get(ctx, "key")
+	s.NoError(err)
+	s.Equal("value", value)
+	s.Equal(time.Minute, s.server.TTL("key"))
+	s.Zero(s.server.TTL("forever"))
+
+	values, err := s.cache.GetMany(ctx, []string{"key", "forever", "missing"})
+	s.NoError(err)
+	s.Equal(map[string]interface{}{"key": "value", "forever": "42"}, values)
+
+	s.NoError(s.cache.SetTTL(ctx, "key", "value", -time.Second))
+	_, err = s.cache.Get(ctx, "key")
+	s.ErrorIs(err, CacheMissErr)
+}
+
+func TestRedisOptions_NewClient(t *testing.T) {
+	tests := []struct {
+		name     string
+		opts     RedisOptions
+		expected interface{}
+	}{
+		{
+			name:     "single node",
+			opts:     RedisOptions{Addrs: []string{"localhost:6379"}},
+			expected: &redis.Client{},
+		},
+		{
+			name:     "cluster with many addresses",
+			opts:     RedisOptions{Addrs: []string{"localhost:7000", "localhost:7001"}},
+			expected: &redis.ClusterClient{},
+		},
+		{
+			name:     "cluster mode with one address",
+			opts:     RedisOptions{Addrs: []string{"localhost:7000"}, ClusterMode: true},
+			expected: &redis.ClusterClient{},
+		},
+		{
+			name:     "sentinel",
+			opts:     RedisOptions{Addrs: []string{"localhost:26379", "localhost:26380"}, MasterName: "mymaster"},
+			expected: &redis.Client{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := tt.opts.newClient()
+			defer client.Close()
+			assert.IsType(t, tt.expected, client)
+		})
+	}
+}
+
+func TestRedisOptions_Universal(t *testing.T) {
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+	opts := RedisOptions{
+		Addrs:        []string{"localhost:6379"},
+		Password:     "secret",
+		DB:           2,
+		TLSConfig:    tlsConfig,
+		PoolSize:     20,
+		DialTimeout:  time.Second,
+		ReadTimeout:  2 * time.Second,
+		WriteTimeout: 3 * time.Second,
+	}
+
+	universal := opts.universal()
+	assert.Equal(t, []string{"localhost:6379"}, universal.Addrs)
+	assert.Equal(t, "secret", universal.Password)
+	assert.Equal(t, 2, universal.DB)
+	assert.Same(t, tlsConfig, universal.TLSConfig)
+	assert.Equal(t, 20, universal.PoolSize)
+	assert.Equal(t, time.Second, universal.DialTimeout)
+	assert.Equal(t, 2*time.Second, universal.ReadTimeout)
+	assert.Equal(t, 3*time.Second, universal.WriteTimeout)
+}
+
+func TestNewRedisCacheWithOptions_ConnectError(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	// nothing listens on port 1
+	rc, err := NewRedisCacheWithOptions(ctx, RedisOptions{
+		Addrs:       []string{"127.0.0.1:1"},
+		DialTimeout: 100 * time.Millisecond,
+		MaxRetries:  -1,
+	})
+	assert.Error(t, err)
+	assert.Nil(t, rc)
+}
+
+func TestEscapeGlob(t *testing.T) {
+	assert.Equal(t, "orders:", escapeGlob("orders:"))
+	assert.Equal(t, `a\*b\?c\[d\]e\\f`, escapeGlob(`a*b?c[d]e\f`))
+}
+
+func (s *RedisTestSuite) TestCompareAndSwap() {
+	ctx := context.Background()
+	s.NoError(s.cache.SetTTL(ctx, "key", "a", time.Minute))
+
+	value, version, err := s.cache.GetWithVersion(ctx, "key")
+	s.NoError(err)
+	s.Equal("a", value)
+
+	swapped, err := s.cache.CompareAndSwap(ctx, "key", version, "b", time.Hour)
+	s.NoError(err)
+	s.True(swapped)
+	s.Equal(time.Hour, s.server.TTL("key"))
+
+	// the old version no longer matches
+	swapped, err = s.cache.CompareAndSwap(ctx, "key", version, "c", time.Hour)
+	s.NoError(err)
+	s.False(swapped)
+
+	value, err = s.cache.Get(ctx, "key")
+	s.NoError(err)
+	s.Equal("b", value)
+}
+
+func (s *RedisTestSuite) TestCompareAndSwap_ABA() {
+	ctx := context.Background()
+	s.NoError(s.cache.SetTTL(ctx, "key", "a", time.Minute))
+	_, version, err := s.cache.GetWithVersion(ctx, "key")
+	s.NoError(err)
+
+	// the same value again is still a new version
+	s.NoError(s.cache.SetTTL(ctx, "key", "b", time.Minute))
+	s.NoError(s.cache.SetTTL(ctx, "key", "a", time.Minute))
+	swapped, err := s.cache.CompareAndSwap(ctx, "key", version, "c", time.Minute)
+	s.NoError(err)
+	s.False(swapped)
+
+	// and so is a key that was deleted and set again
+	_, version, err = s.cache.GetWithVersion(ctx, "key")
+	s.NoError(err)
+	s.NoError(s.cache.Delete(ctx, "key"))
+	s.NoError(s.cache.SetTTL(ctx, "key", "a", time.Minute))
+	swapped, err = s.cache.CompareAndSwap(ctx, "key", version, "c", time.Minute)
+	s.NoError(err)
+	s.False(swapped)
+}
+
+func (s *RedisTestSuite) TestCompareAndSwap_MissingOrRemoved() {
+	ctx := context.Background()
+	swapped, err := s.cache.CompareAndSwap(ctx, "missing", 1, "value", time.Minute)
+	s.NoError(err)
+	s.False(swapped)
+
+	s.NoError(s.cache.SetTTL(ctx, "key", "value", time.Minute))
+	_, version, err := s.cache.GetWithVersion(ctx, "key")
+	s.NoError(err)
+
+	// a negative ttl swaps by removing the key
+	swapped, err = s.cache.CompareAndSwap(ctx, "key", version, "value", -time.Second)
+	s.NoError(err)
+	s.True(swapped)
+	s.False(s.server.Exists("key"))
+}
+
+func (s *RedisTestSuite) TestIncrement() {
+	ctx := context.Background()
+	n, err := s.cache.Increment(ctx, "counter", 5, time.Minute)
+	s.NoError(err)
+	s.Equal(int64(5), n)
+	s.Equal(time.Minute, s.server.TTL("counter"))
+
+	_, version, err := s.cache.GetWithVersion(ctx, "counter")
+	s.NoError(err)
+
+	// the ttl is only set when the key is created
+	n, err = s.cache.Decrement(ctx, "counter", 2, time.Hour)
+	s.NoError(err)
+	s.Equal(int64(3), n)
+	s.Equal(time.Minute, s.server.TTL("counter"))
+
+	value, newVersion, err := s.cache.GetWithVersion(ctx, "counter")
+	s.NoError(err)
+	s.Equal("3", value)
+	s.Equal(version+1, newVersion)
+
+	// counters can be started with Set
+	s.NoError(s.cache.SetTTL(ctx, "set", 10, time.Minute))
+	n, err = s.cache.Increment(ctx, "set", 1, time.Minute)
+	s.NoError(err)
+	s.Equal(int64(11), n)
+}
+
+func (s *RedisTestSuite) TestIncrement_NotAnInteger() {
+	ctx := context.Background()
+	s.NoError(s.cache.SetTTL(ctx, "key", "value", time.Minute))
+
+	_, err := s.cache.Increment(ctx, "key", 1, time.Minute)
+	s.ErrorIs(err, TypeMismatchErr)
+}
+
+func (s *RedisTestSuite) TestSetNX() {
+	ctx := context.Background()
+	added, err := s.cache.SetNX(ctx, "key", "first", time.Minute)
+	s.NoError(err)
+	s.True(added)
+
+	added, err = s.cache.SetNX(ctx, "key", "second", time.Minute)
+	s.NoError(err)
+	s.False(added)
+
+	value, err := s.cache.Get(ctx, "key")
+	s.NoError(err)
+	s.Equal("first", value)
+}
+
+func (s *RedisTestSuite) TestGetAndDelete() {
+	ctx := context.Background()
+	s.NoError(s.cache.SetTTL(ctx, "key", "value", time.Minute))
+
+	value, err := s.cache.GetAndDelete(ctx, "key")
+	s.NoError(err)
+	s.Equal("value", value)
+
+	_, err = s.cache.GetAndDelete(ctx, "key")
+	s.ErrorIs(err, CacheMissErr)
+}
+
+func (s *RedisTestSuite) TestSetWithTags_ReplacesTags() {
+	ctx := context.Background()
+	s.NoError(s.cache.SetWithTags(ctx, "key", "value", time.Minute, "a", "b"))
+	s.NoError(s.cache.SetWithTags(ctx, "other", "value", time.Minute, "a"))
+	s.NoError(s.cache.SetWithTags(ctx, "key", "value", time.Minute, "c"))
+
+	members, _ := s.server.Members(tagKey("a"))
+	s.Equal([]string{"other"}, members)
+	s.False(s.server.Exists(tagKey("b")))
+
+	s.NoError(s.cache.InvalidateTags(ctx, "a", "b"))
+	_, err := s.cache.Get(ctx, "key")
+	s.NoError(err)
+	_, err = s.cache.Get(ctx, "other")
+	s.ErrorIs(err, CacheMissErr)
+
+	s.NoError(s.cache.InvalidateTags(ctx, "c"))
+	_, err = s.cache.Get(ctx, "key")
+	s.ErrorIs(err, CacheMissErr)
+}
+
+func (s *RedisTestSuite) TestSetWithTags_UntaggedBySetAndDelete() {
+	ctx := context.Background()
+	s.NoError(s.cache.SetWithTags(ctx, "set", "value", time.Minute, "tag"))
+	s.NoError(s.cache.SetWithTags(ctx, "deleted", "value", time.Minute, "tag"))
+	s.NoError(s.cache.SetWithTags(ctx, "many", "value", time.Minute, "tag"))
+
+	s.NoError(s.cache.SetTTL(ctx, "set", "untagged", time.Minute))
+	s.NoError(s.cache.Delete(ctx, "deleted"))
+	s.NoError(s.cache.DeleteMany(ctx, []string{"many"}))
+	s.False(s.server.Exists(tagKey("tag")))
+
+	s.NoError(s.cache.InvalidateTags(ctx, "tag"))
+	value, err := s.cache.Get(ctx, "set")
+	s.NoError(err)
+	s.Equal("untagged", value)
+}
+
+func (s *RedisTestSuite) TestInvalidateTags_SkipsExpiredAndSetAgain() {
+	ctx := context.Background()
+	s.NoError(s.cache.SetWithTags(ctx, "key", "value", time.Second, "tag"))
+	s.NoError(s.cache.SetWithTags(ctx, "long", "value", time.Hour, "tag"))
+
+	// the key expires but stays in the set, which lives as long as "long"
+	s.server.FastForward(2 * time.Second)
+	s.NoError(s.cache.SetTTL(ctx, "key", "new", time.Minute))
+
+	s.NoError(s.cache.InvalidateTags(ctx, "tag"))
+	value, err := s.cache.Get(ctx, "key")
+	s.NoError(err)
+	s.Equal("new", value)
+	_, err = s.cache.Get(ctx, "long")
+	s.ErrorIs(err, CacheMissErr)
+}
+
+func (s *RedisTestSuite) TestSetWithTags_Ring() {
+	// a ring can put the tag sets on other shards than the keys
+	ring := redis.NewRing(&redis.RingOptions{Addrs: map[string]string{"shard": s.server.Addr()}})
+	rc, err := NewRedisCacheFromClient(context.Background(), ring)
+	s.Require().NoError(err)
+	defer rc.Close()
+
+	ctx := context.Background()
+	s.NoError(rc.SetWithTags(ctx, "key", "value", time.Minute, "old"))
+	s.NoError(rc.SetWithTags(ctx, "key", "value", time.Minute, "new"))
+	s.False(s.server.Exists(tagKey("old")))
+
+	s.NoError(rc.InvalidateTags(ctx, "old"))
+	_, err = rc.Get(ctx, "key")
+	s.NoError(err)
+
+	s.NoError(rc.InvalidateTags(ctx, "new"))
+	_, err = rc.Get(ctx, "key")
+	s.ErrorIs(err, CacheMissErr)
+}
+
+func (s *RedisTestSuite) TestSize_SkipsTagSets() {
+	ctx := context.Background()
+	s.NoError(s.cache.SetWithTags(ctx, "cache-user:1", "value", time.Minute, "users", "admins"))
+	s.NoError(s.cache.SetTTL(ctx, "cache-user:2", "value", time.Minute))
+	s.True(s.server.Exists(tagKey("users")))
+
+	s.Equal(uint64(2), s.cache.Size(ctx))
+	s.Equal(uint64(2), s.cache.SizePrefix(ctx, "cache-"))
+}
+
+func (s *RedisTestSuite) TestRing_EveryShard() {
+	ring := redis.NewRing(&redis.RingOptions{Addrs: map[string]string{"shard": s.server.Addr()}})
+	rc, err := NewRedisCacheFromClient(context.Background(), ring)
+	s.Require().NoError(err)
+	defer rc.Close()
+
+	ctx := context.Background()
+	s.NoError(rc.SetTTL(ctx, "orders:1", "value", time.Minute))
+	s.NoError(rc.SetTTL(ctx, "users:1", "value", time.Minute))
+	s.Equal(uint64(2), rc.Size(ctx))
+	s.Equal(uint64(1), rc.SizePrefix(ctx, "orders:"))
+
+	s.NoError(rc.DeletePrefix(ctx, "orders:"))
+	s.Equal(uint64(1), rc.Size(ctx))
+
+	rc.Purge(ctx)
+	s.Equal(uint64(0), rc.Size(ctx))
+}
+
+func (s *RedisTestSuite) TestDeletePattern() {
+	ctx := context.Background()
+	s.NoError(s.cache.SetWithTags(ctx, "session:1", "value", time.Minute, "session:tag"))
+	s.NoError(s.cache.SetTTL(ctx, "session:2", "value", time.Minute))
+	s.NoError(s.cache.SetWithTags(ctx, "user:1", "value", time.Minute, "session:tag"))
+
+	s.NoError(s.cache.DeletePattern(ctx, "session:*"))
+	s.Equal([]string{metaKey("user:1"), tagKey("session:tag"), "user:1"}, s.server.Keys())
+
+	// the tag set still matches the pattern
+	s.NoError(s.cache.DeletePattern(ctx, "*"))
+	s.Empty(s.server.Keys())
+}
@@ -0,0 +1,57 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// staleCache is implemented by every RefreshingCache so getOrRefresh can tell stale values apart
+type staleCache interface {
+	Cache
+
+	// getStale retrieves data given a key and whether the value is past its ttl
+	getStale(ctx context.Context, key string) (interface{}, bool, error)
+
+	SetWithGrace(ctx context.Context, key string, value interface{}, ttl, grace time.Duration) error
+}
+
+// getOrRefresh returns the cached value, starting a background refresh if it is stale.
+// A missing value is loaded like getOrLoad, sharing the same flights as the refreshes
+func getOrRefresh(ctx context.Context, c staleCache, group *flightGroup, key string, ttl, grace time.Duration, loader LoaderFunc) (interface{}, error) {
+	load := func(ctx context.Context) (interface{}, error) {
+		value, err := loader(ctx, key)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := c.SetWithGrace(ctx, key, value, ttl, grace); err != nil {
+			log.Err(err).Str("key", key).Msg("failed to cache loaded value")
+		}
+		return value, nil
+	}
+
+	value, stale, err := c.getStale(ctx, key)
+	if err == nil {
+		if stale {
+			// the refresh outlives this caller
+			group.start(ctx, key, func(ctx context.Context) (interface{}, error) {
+				value, err := load(ctx)
+				if err != nil {
+					log.Err(err).Str("key", key).Msg("failed to refresh stale value, serving it until it expires")
+				}
+				return value, err
+			})
+		}
+		return value, nil
+	}
+
+	// a broken cache shouldn't take down the caller, fall back to the loader
+	if !errors.Is(err, CacheMissErr) {
+		log.Err(err).Str("key", key).Msg("failed to get from cache, falling back to loader")
+	}
+
+	return group.do(ctx, key, load)
+}
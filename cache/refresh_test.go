@@ -0,0 +1,167 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type RefreshTestSuite struct {
+	suite.Suite
+	caches map[string]RefreshingCache
+}
+
+func TestRefreshSuite(t *testing.T) {
+	suite.Run(t, new(RefreshTestSuite))
+}
+
+// SetupTest runs before each test in the suite
+func (s *RefreshTestSuite) SetupTest() {
+	s.caches = map[string]RefreshingCache{
+		"in memory": NewInMemoryCache(),
+		"lru":       NewLRUCache(10),
+		"lfu":       NewLFUCache(10),
+		"tinylfu":   NewTinyLFUCache(10),
+		"sharded":   NewShardedCache(2, 20),
+	}
+}
+
+func (s *RefreshTestSuite) TestGetOrRefresh_Fresh() {
+	for name, c := range s.caches {
+		s.Run(name, func() {
+			c.SetWithGrace(context.Background(), "key", "cached", time.Minute, time.Minute)
+
+			value, err := c.GetOrRefresh(context.Background(), "key", time.Minute, time.Minute, func(ctx context.Context, key string) (interface{}, error) {
+				s.Fail("loader should not be called while the value is fresh")
+				return nil, nil
+			})
+			s.NoError(err)
+			s.Equal("cached", value)
+		})
+	}
+}
+
+func (s *RefreshTestSuite) TestGetOrRefresh_StaleRefreshesOnce() {
+	for name, c := range s.caches {
+		s.Run(name, func() {
+			c.SetWithGrace(context.Background(), "key", "stale", 10*time.Millisecond, time.Minute)
+			time.Sleep(20 * time.Millisecond)
+
+			var calls atomic.Int32
+			release := make(chan struct{})
+			loader := func(ctx context.Context, key string) (interface{}, error) {
+				calls.Add(1)
+				<-release
+				return "refreshed", nil
+			}
+
+			// every caller gets the stale value right away while the refresh is blocked
+			var wg sync.WaitGroup
+			for range 10 {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					value, err := c.GetOrRefresh(context.Background(), "key", time.Minute, time.Minute, loader)
+					s.NoError(err)
+					s.Equal("stale", value)
+				}()
+			}
+			wg.Wait()
+			close(release)
+
+			s.Eventually(func() bool {
+				value, _ := c.Get(context.Background(), "key")
+				return value == "refreshed"
+			}, time.Second, time.Millisecond)
+			s.Equal(int32(1), calls.Load())
+		})
+	}
+}
+
+func (s *RefreshTestSuite) TestGetOrRefresh_ExpiredLoads() {
+	for name, c := range s.caches {
+		s.Run(name, func() {
+			c.SetWithGrace(context.Background(), "key", "stale", 5*time.Millisecond, 5*time.Millisecond)
+			time.Sleep(20 * time.Millisecond)
+
+			value, err := c.GetOrRefresh(context.Background(), "key", time.Minute, time.Minute, func(ctx context.Context, key string) (interface{}, error) {
+				return "loaded", nil
+			})
+			s.NoError(err)
+			s.Equal("loaded", value)
+		})
+	}
+}
+
+func (s *RefreshTestSuite) TestGetOrRefresh_FailedRefreshKeepsStale() {
+	for name, c := range s.caches {
+		s.Run(name, func() {
+			c.SetWithGrace(context.Background(), "key", "stale", 10*time.Millisecond, time.Minute)
+			time.Sleep(20 * time.Millisecond)
+
+			var calls atomic.Int32
+			loader := func(ctx context.Context, key string) (interface{}, error) {
+				calls.Add(1)
+				return nil, errors.New("origin is down")
+			}
+
+			value, err := c.GetOrRefresh(context.Background(), "key", time.Minute, time.Minute, loader)
+			s.NoError(err)
+			s.Equal("stale", value)
+
+			s.Eventually(func() bool { return calls.Load() == 1 }, time.Second, time.Millisecond)
+
+			value, err = c.Get(context.Background(), "key")
+			s.NoError(err)
+			s.Equal("stale", value)
+		})
+	}
+}
+
+func (s *RefreshTestSuite) TestGetOrRefresh_RefreshOutlivesCaller() {
+	for name, c := range s.caches {
+		s.Run(name, func() {
+			c.SetWithGrace(context.Background(), "key", "stale", 10*time.Millisecond, time.Minute)
+			time.Sleep(20 * time.Millisecond)
+
+			ctx, cancel := context.WithCancel(context.Background())
+			release := make(chan struct{})
+			value, err := c.GetOrRefresh(ctx, "key", time.Minute, time.Minute, func(ctx context.Context, key string) (interface{}, error) {
+				<-release
+				return "refreshed", ctx.Err()
+			})
+			s.NoError(err)
+			s.Equal("stale", value)
+
+			cancel()
+			close(release)
+
+			s.Eventually(func() bool {
+				value, _ := c.Get(context.Background(), "key")
+				return value == "refreshed"
+			}, time.Second, time.Millisecond)
+		})
+	}
+}
+
+func (s *RefreshTestSuite) TestSetWithGrace_ExpiresAfterGrace() {
+	for name, c := range s.caches {
+		s.Run(name, func() {
+			c.SetWithGrace(context.Background(), "key", "value", 5*time.Millisecond, 50*time.Millisecond)
+
+			time.Sleep(10 * time.Millisecond)
+			value, err := c.Get(context.Background(), "key")
+			s.NoError(err, "stale values are still returned by Get")
+			s.Equal("value", value)
+
+			time.Sleep(50 * time.Millisecond)
+			_, err = c.Get(context.Background(), "key")
+			s.ErrorIs(err, CacheMissErr)
+		})
+	}
+}
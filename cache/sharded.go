@@ -0,0 +1,340 @@
+package cache
+
+import (
+	"context"
+	"io"
+	"runtime"
+	"time"
+)
+
+// shardedCache splits the keys across independent LRU shards, each with its own lock,
+// so concurrent callers rarely wait on each other
+type shardedCache struct {
+	shards  []*policyCache
+	mask    uint64
+	loads   flightGroup
+	janitor *janitor
+}
+
+// NewShardedCache creates a cache of LRU shards that together hold about capacity items.
+// The number of shards is rounded up to a power of two, 0 picks one based on GOMAXPROCS. It is lowered
+// until every shard holds at least MinimumCapacity, so a small cache doesn't end up much bigger than asked for.
+// Each shard's share is rounded up, so the total is at most one item per shard over the capacity. Capacities below
+// MinimumCapacity default to DefaultCapacity like NewLRUCache. WithMaxBytes is split evenly across the shards and a single janitor sweeps all of them
+func NewShardedCache(shards int, capacity uint64, opts ...Option) *shardedCache {
+	if shards <= 0 {
+		shards = runtime.GOMAXPROCS(0) * 4
+	}
+	capacity = validCapacity(capacity)
+	count := nextPowerOfTwo(uint64(shards))
+	for count > 1 && capacity/count < MinimumCapacity {
+		count /= 2
+	}
+
+	perShard := (capacity + count - 1) / count
+
+	o := newOptions(opts)
+	shardOpts := append(opts[:len(opts):len(opts)], WithCleanupInterval(0))
+	if o.maxBytes > 0 {
+		// rounded up, a budget of 0 would turn it off
+		shardOpts = append(shardOpts, WithMaxBytes((o.maxBytes+count-1)/count))
+	}
+
+	c := &shardedCache{
+		shards: make([]*policyCache, count),
+		mask:   count - 1,
+	}
+	for i := range c.shards {
+		c.shards[i] = NewLRUCache(perShard, shardOpts...)
+	}
+
+	if o.cleanupInterval > 0 {
+		c.janitor = startJanitor(o.cleanupInterval, c.DeleteExpired)
+	}
+
+	return c
+}
+
+// Get retrieves data given a key
+func (c *shardedCache) Get(ctx context.Context, key string) (interface{}, error) {
+	return c.shard(key).Get(ctx, key)
+}
+
+// Set adds the value for a given key, ttl is in seconds
+func (c *shardedCache) Set(ctx context.Context, key string, value interface{}, ttl int) error {
+	return c.SetTTL(ctx, key, value, seconds(ttl))
+}
+
+// SetTTL adds the value for a given key that lives for the ttl
+func (c *shardedCache) SetTTL(ctx context.Context, key string, value interface{}, ttl time.Duration) error {
+	return c.shard(key).SetTTL(ctx, key, value, ttl)
+}
+
+// SetWithGrace adds the value for a given key that is fresh for the ttl, then stale for the grace period before it expires
+func (c *shardedCache) SetWithGrace(ctx context.Context, key string, value interface{}, ttl, grace time.Duration) error {
+	return c.shard(key).SetWithGrace(ctx, key, value, ttl, grace)
+}
+
+// GetOrLoad retrieves data given a key, calling the loader once on a cache miss
+func (c *shardedCache) GetOrLoad(ctx context.Context, key string, ttl time.Duration, loader LoaderFunc) (interface{}, error) {
+	return getOrLoad(ctx, c, &c.loads, key, ttl, loader)
+}
+
+// GetOrRefresh retrieves data given a key, a stale value is returned while the loader refreshes it in the background
+func (c *shardedCache) GetOrRefresh(ctx context.Context, key string, ttl, grace time.Duration, loader LoaderFunc) (interface{}, error) {
+	return getOrRefresh(ctx, c, &c.loads, key, ttl, grace, loader)
+}
+
+// SetWithTags adds the value for a given key that lives for the ttl, the item can be invalidated by any of its tags
+func (c *shardedCache) SetWithTags(ctx context.Context, key string, value interface{}, ttl time.Duration, tags ...string) error {
+	return c.shard(key).SetWithTags(ctx, key, value, ttl, tags...)
+}
+
+// InvalidateTags removes every item that was set with any of the tags from every shard
+func (c *shardedCache) InvalidateTags(ctx context.Context, tags ...string) error {
+	for _, shard := range c.shards {
+		shard.InvalidateTags(ctx, tags...)
+	}
+	return nil
+}
+
+// SetNX adds the value only if the key doesn't exist, returning whether it was added
+func (c *shardedCache) SetNX(ctx context.Context, key string, value interface{}, ttl time.Duration) (bool, error) {
+	return c.shard(key).SetNX(ctx, key, value, ttl)
+}
+
+// GetWithVersion retrieves data given a key along with its version for CompareAndSwap
+func (c *shardedCache) GetWithVersion(ctx context.Context, key string) (interface{}, uint64, error) {
+	return c.shard(key).GetWithVersion(ctx, key)
+}
+
+// CompareAndSwap replaces the value only if the key still has the version, returning whether it was replaced
+func (c *shardedCache) CompareAndSwap(ctx context.Context, key string, version uint64, value interface{}, ttl time.Duration) (bool, error) {
+	return c.shard(key).CompareAndSwap(ctx, key, version, value, ttl)
+}
+
+// Increment adds delta to the int64 at key and returns the result, a missing key starts at 0 and lives for the ttl
+func (c *shardedCache) Increment(ctx context.Context, key string, delta int64, ttl time.Duration) (int64, error) {
+	return c.shard(key).Increment(ctx, key, delta, ttl)
+}
+
+// Decrement subtracts delta from the int64 at key, see Increment
+func (c *shardedCache) Decrement(ctx context.Context, key string, delta int64, ttl time.Duration) (int64, error) {
+	return c.shard(key).Decrement(ctx, key, delta, ttl)
+}
+
+// GetAndDelete retrieves data given a key and removes it
+func (c *shardedCache) GetAndDelete(ctx context.Context, key string) (interface{}, error) {
+	return c.shard(key).GetAndDelete(ctx, key)
+}
+
+// GetMany retrieves data for all the keys, taking each shard's lock once
+func (c *shardedCache) GetMany(ctx context.Context, keys []string) (map[string]interface{}, error) {
+	values := make(map[string]interface{}, len(keys))
+	for i, shardKeys := range c.groupKeys(keys) {
+		if len(shardKeys) == 0 {
+			continue
+		}
+
+		shardValues, err := c.shards[i].GetMany(ctx, shardKeys)
+		if err != nil {
+			return nil, err
+		}
+		for key, value := range shardValues {
+			values[key] = value
+		}
+	}
+	return values, nil
+}
+
+// SetMany adds all the values for their keys, taking each shard's lock once
+func (c *shardedCache) SetMany(ctx context.Context, items map[string]interface{}, ttl time.Duration) error {
+	grouped := make([]map[string]interface{}, len(c.shards))
+	for key, value := range items {
+		i := c.index(key)
+		if grouped[i] == nil {
+			grouped[i] = make(map[string]interface{})
+		}
+		grouped[i][key] = value
+	}
+
+	for i, shardItems := range grouped {
+		if len(shardItems) == 0 {
+			continue
+		}
+		if err := c.shards[i].SetMany(ctx, shardItems, ttl); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Delete removes the item from the cache given the key
+func (c *shardedCache) Delete(ctx context.Context, key string) error {
+	return c.shard(key).Delete(ctx, key)
+}
+
+// DeleteMany removes all the items from the cache given the keys
+func (c *shardedCache) DeleteMany(ctx context.Context, keys []string) error {
+	for i, shardKeys := range c.groupKeys(keys) {
+		if len(shardKeys) == 0 {
+			continue
+		}
+		if err := c.shards[i].DeleteMany(ctx, shardKeys); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Purge clear all items in the cache
+func (c *shardedCache) Purge(ctx context.Context) {
+	for _, shard := range c.shards {
+		shard.Purge(ctx)
+	}
+}
+
+// Size returns the number of elements in the cache
+func (c *shardedCache) Size(ctx context.Context) uint64 {
+	var size uint64
+	for _, shard := range c.shards {
+		size += shard.Size(ctx)
+	}
+	return size
+}
+
+// DeletePrefix removes every item whose key starts with the prefix
+func (c *shardedCache) DeletePrefix(ctx context.Context, prefix string) error {
+	for _, shard := range c.shards {
+		if err := shard.DeletePrefix(ctx, prefix); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SizePrefix returns the number of items whose key starts with the prefix
+func (c *shardedCache) SizePrefix(ctx context.Context, prefix string) uint64 {
+	var size uint64
+	for _, shard := range c.shards {
+		size += shard.SizePrefix(ctx, prefix)
+	}
+	return size
+}
+
+// Stats returns the combined counters of every shard
+func (c *shardedCache) Stats(ctx context.Context) Stats {
+	var stats Stats
+	for _, shard := range c.shards {
+		s := shard.Stats(ctx)
+		stats.Hits += s.Hits
+		stats.Misses += s.Misses
+		stats.Sets += s.Sets
+		stats.Evictions += s.Evictions
+		stats.Expirations += s.Expirations
+		stats.Size += s.Size
+		stats.Bytes += s.Bytes
+	}
+	return stats
+}
+
+// DeleteExpired removes every expired item from every shard
+func (c *shardedCache) DeleteExpired() {
+	for _, shard := range c.shards {
+		shard.DeleteExpired()
+	}
+}
+
+// Close stops the background janitor if one was started
+func (c *shardedCache) Close() error {
+	c.janitor.Stop()
+	return nil
+}
+
+// Save writes every unexpired item to w with the values encoded by the codec
+func (c *shardedCache) Save(ctx context.Context, w io.Writer, codec Codec) error {
+	return writeSnapshot(w, codec, c.snapshot())
+}
+
+// Load adds the items saved in r, items that expired in the meantime are skipped and the rest keep their remaining ttl
+func (c *shardedCache) Load(ctx context.Context, r io.Reader, codec Codec) error {
+	items, err := readSnapshot(r, codec)
+	if err != nil {
+		return err
+	}
+	c.restore(items)
+	return nil
+}
+
+// getWithTTL retrieves data given a key and how long until it expires
+func (c *shardedCache) getWithTTL(ctx context.Context, key string) (interface{}, time.Duration, error) {
+	return c.shard(key).getWithTTL(ctx, key)
+}
+
+// getStale retrieves data given a key and whether the value is past its ttl
+func (c *shardedCache) getStale(ctx context.Context, key string) (interface{}, bool, error) {
+	return c.shard(key).getStale(ctx, key)
+}
+
+// snapshot copies every unexpired item shard by shard
+func (c *shardedCache) snapshot() []cacheItem {
+	var items []cacheItem
+	for _, shard := range c.shards {
+		items = append(items, shard.snapshot()...)
+	}
+	return items
+}
+
+// restore adds the items loaded from a snapshot to their shards, keeping their order within each shard
+func (c *shardedCache) restore(items []*cacheItem) {
+	grouped := make([][]*cacheItem, len(c.shards))
+	for _, item := range items {
+		i := c.index(item.key)
+		grouped[i] = append(grouped[i], item)
+	}
+
+	for i, shard := range c.shards {
+		shard.restore(grouped[i])
+	}
+}
+
+func (c *shardedCache) shard(key string) *policyCache {
+	return c.shards[c.index(key)]
+}
+
+func (c *shardedCache) index(key string) uint64 {
+	return fnv64a(key) & c.mask
+}
+
+// groupKeys splits the keys up by the shard they belong to
+func (c *shardedCache) groupKeys(keys []string) [][]string {
+	grouped := make([][]string, len(c.shards))
+	for _, key := range keys {
+		i := c.index(key)
+		grouped[i] = append(grouped[i], key)
+	}
+	return grouped
+}
+
+// fnv64a hashes the key without allocating like hash/fnv would
+func fnv64a(key string) uint64 {
+	const (
+		offset64 = 14695981039346656037
+		prime64  = 1099511628211
+	)
+
+	hash := uint64(offset64)
+	for i := 0; i < len(key); i++ {
+		hash ^= uint64(key[i])
+		hash *= prime64
+	}
+	return hash
+}
+
+func nextPowerOfTwo(n uint64) uint64 {
+	power := uint64(1)
+	for power < n {
+		power <<= 1
+	}
+	return power
+}
@@ -0,0 +1,195 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type ShardedTestSuite struct {
+	suite.Suite
+	cache *shardedCache
+}
+
+func TestShardedSuite(t *testing.T) {
+	suite.Run(t, new(ShardedTestSuite))
+}
+
+// SetupTest runs before each test in the suite
+func (s *ShardedTestSuite) SetupTest() {
+	s.cache = NewShardedCache(4, 100)
+}
+
+// TearDownTest runs after each test in the suite
+func (s *ShardedTestSuite) TearDownTest() {
+	s.cache.Close()
+}
+
+func (s *ShardedTestSuite) TestSharded_ShardCount() {
+	s.Len(NewShardedCache(3, 100).shards, 4)
+	s.Len(NewShardedCache(16, 1000).shards, 16)
+	s.NotEmpty(NewShardedCache(0, 100).shards)
+
+	// fewer shards rather than shards below the minimum
+	s.Len(NewShardedCache(16, 100).shards, 8)
+	s.Len(NewShardedCache(16, 10).shards, 1)
+	s.Equal(MinimumCapacity, shardCapacity(NewShardedCache(16, 10).shards[0]))
+}
+
+func (s *ShardedTestSuite) TestSharded_CapacityNotInflated() {
+	for _, capacity := range []uint64{10, 100, 1000} {
+		sharded := NewShardedCache(0, capacity)
+
+		var total uint64
+		for _, shard := range sharded.shards {
+			s.GreaterOrEqual(shardCapacity(shard), MinimumCapacity)
+			total += shardCapacity(shard)
+		}
+		// only rounding up per shard adds to it
+		s.GreaterOrEqual(total, capacity)
+		s.Less(total, capacity+uint64(len(sharded.shards)))
+	}
+}
+
+func (s *ShardedTestSuite) TestSharded_SmallCapacity() {
+	// the same as one LRU cache of that capacity
+	sharded := NewShardedCache(16, 15)
+	s.Len(sharded.shards, 1)
+	s.Equal(uint64(15), shardCapacity(sharded.shards[0]))
+
+	// below the minimum the default is split across the shards instead
+	sharded = NewShardedCache(16, 5)
+	var total uint64
+	for _, shard := range sharded.shards {
+		total += shardCapacity(shard)
+	}
+	s.GreaterOrEqual(total, DefaultCapacity)
+	s.Less(total, DefaultCapacity+uint64(len(sharded.shards)))
+
+	// 2 shards of 11 since 4 would be below the minimum, one item over
+	sharded = NewShardedCache(4, 21)
+	s.Len(sharded.shards, 2)
+	s.Equal(uint64(11), shardCapacity(sharded.shards[0]))
+}
+
+func (s *ShardedTestSuite) TestSharded_MaxBytesNeverZero() {
+	sharded := NewShardedCache(16, 1000, WithMaxBytes(5))
+	s.Len(sharded.shards, 16)
+	for _, shard := range sharded.shards {
+		s.Equal(uint64(1), shard.opts.maxBytes)
+	}
+
+	sharded = NewShardedCache(4, 1000, WithMaxBytes(1000))
+	for _, shard := range sharded.shards {
+		s.Equal(uint64(250), shard.opts.maxBytes)
+	}
+}
+
+func (s *ShardedTestSuite) TestSharded_SetGetDelete() {
+	for i := range 50 {
+		err := s.cache.SetTTL(context.Background(), strconv.Itoa(i), i, time.Minute)
+		s.NoError(err)
+	}
+
+	for i := range 50 {
+		value, err := s.cache.Get(context.Background(), strconv.Itoa(i))
+		s.NoError(err)
+		s.Equal(i, value)
+	}
+
+	err := s.cache.Delete(context.Background(), "0")
+	s.NoError(err)
+
+	_, err = s.cache.Get(context.Background(), "0")
+	s.True(errors.Is(err, CacheMissErr))
+}
+
+func (s *ShardedTestSuite) TestSharded_KeysAreSpreadAcrossShards() {
+	for i := range 100 {
+		s.cache.SetTTL(context.Background(), strconv.Itoa(i), i, time.Minute)
+	}
+
+	for _, shard := range s.cache.shards {
+		s.NotZero(shard.Size(context.Background()))
+	}
+}
+
+func (s *ShardedTestSuite) TestSharded_SizeAndPurge() {
+	for i := range 50 {
+		s.cache.SetTTL(context.Background(), strconv.Itoa(i), i, time.Minute)
+	}
+	s.Equal(uint64(50), s.cache.Size(context.Background()))
+	s.Equal(uint64(50), s.cache.Stats(context.Background()).Sets)
+
+	s.cache.Purge(context.Background())
+	s.Equal(uint64(0), s.cache.Size(context.Background()))
+}
+
+func (s *ShardedTestSuite) TestSharded_Batch() {
+	items := map[string]interface{}{}
+	keys := []string{}
+	for i := range 20 {
+		items[strconv.Itoa(i)] = i
+		keys = append(keys, strconv.Itoa(i))
+	}
+
+	err := s.cache.SetMany(context.Background(), items, time.Minute)
+	s.NoError(err)
+
+	values, err := s.cache.GetMany(context.Background(), append(keys, "does_not_exist"))
+	s.NoError(err)
+	s.Equal(items, values)
+
+	err = s.cache.DeleteMany(context.Background(), keys)
+	s.NoError(err)
+	s.Equal(uint64(0), s.cache.Size(context.Background()))
+}
+
+func (s *ShardedTestSuite) TestSharded_Janitor() {
+	s.cache = NewShardedCache(4, 100, WithCleanupInterval(10*time.Millisecond))
+
+	for i := range 20 {
+		s.cache.SetTTL(context.Background(), strconv.Itoa(i), i, time.Millisecond)
+	}
+
+	s.Eventually(func() bool {
+		return s.cache.Size(context.Background()) == 0
+	}, time.Second, 10*time.Millisecond)
+	s.Equal(uint64(20), s.cache.Stats(context.Background()).Expirations)
+
+	// the shards don't run their own janitors
+	for _, shard := range s.cache.shards {
+		s.Nil(shard.janitor)
+	}
+}
+
+func (s *ShardedTestSuite) TestSharded_Concurrent() {
+	var wg sync.WaitGroup
+	for g := range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range 500 {
+				key := strconv.Itoa((g * i) % 200)
+				s.cache.SetTTL(context.Background(), key, i, time.Minute)
+				s.cache.Get(context.Background(), key)
+				if i%50 == 0 {
+					s.cache.Delete(context.Background(), key)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	s.LessOrEqual(s.cache.Size(context.Background()), uint64(4*25))
+}
+
+// shardCapacity is the number of items the LRU shard holds
+func shardCapacity(shard *policyCache) uint64 {
+	return shard.policy.(*lruPolicy).capacity
+}
@@ -0,0 +1,90 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/rs/zerolog/log"
+)
+
+var errLoaderPanicked = errors.New("cache: loader panicked")
+
+// flight is a single in progress load that any number of callers can wait on
+type flight struct {
+	done  chan struct{}
+	value interface{}
+	err   error
+}
+
+// flightGroup collapses concurrent calls for the same key into a single call.
+// The zero value is ready to use
+type flightGroup struct {
+	mu      sync.Mutex
+	flights map[string]*flight
+}
+
+// do runs fn once per key for all concurrent callers. Every caller receives the same value and error.
+// fn gets the first caller's context without its cancellation, so a caller giving up neither cancels
+// the call nor fails it for the others, it only stops waiting
+func (g *flightGroup) do(ctx context.Context, key string, fn func(ctx context.Context) (interface{}, error)) (interface{}, error) {
+	g.mu.Lock()
+	f, found := g.flights[key]
+	if !found {
+		f = g.add(key)
+		go g.run(context.WithoutCancel(ctx), key, f, fn)
+	}
+	g.mu.Unlock()
+
+	select {
+	case <-f.done:
+		return f.value, f.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// start runs fn in the background unless a call for the key is already in progress, returning whether it started.
+// Callers of do for the same key wait on it like any other call
+func (g *flightGroup) start(ctx context.Context, key string, fn func(ctx context.Context) (interface{}, error)) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if _, found := g.flights[key]; found {
+		return false
+	}
+
+	go g.run(context.WithoutCancel(ctx), key, g.add(key), fn)
+	return true
+}
+
+// add must be called while holding the lock
+func (g *flightGroup) add(key string) *flight {
+	if g.flights == nil {
+		g.flights = make(map[string]*flight)
+	}
+
+	// if fn panics, the waiters will be released with this error
+	f := &flight{
+		done: make(chan struct{}),
+		err:  errLoaderPanicked,
+	}
+	g.flights[key] = f
+	return f
+}
+
+// run calls fn in its own goroutine, nobody is there to receive a panic so it is logged
+// and the waiters are released with errLoaderPanicked
+func (g *flightGroup) run(ctx context.Context, key string, f *flight, fn func(ctx context.Context) (interface{}, error)) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Error().Str("key", key).Interface("panic", r).Msg("load panicked")
+		}
+
+		g.mu.Lock()
+		delete(g.flights, key)
+		g.mu.Unlock()
+		close(f.done)
+	}()
+
+	f.value, f.err = fn(ctx)
+}
@@ -0,0 +1,167 @@
+package cache
+
+import (
+	"context"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// snapshotVersion is bumped whenever snapshotEntry changes in a way older snapshots can't be read
+const snapshotVersion = 1
+
+type snapshotHeader struct {
+	Version int
+}
+
+// snapshotEntry is a single item in a snapshot. Expirations are absolute so time spent between saving and loading counts
+type snapshotEntry struct {
+	Key            string
+	Value          []byte
+	Expiration     int64
+	SoftExpiration int64
+	Tags           []string
+}
+
+// writeSnapshot encodes the items in order, items whose value the codec can't encode are logged and left out.
+// The codec has to keep the types of the values, otherwise Increment or a TypedCache would fail on them after loading
+func writeSnapshot(w io.Writer, codec Codec, items []cacheItem) error {
+	if !keepsTypes(codec) {
+		return UntypedCodecErr
+	}
+
+	enc := gob.NewEncoder(w)
+	if err := enc.Encode(snapshotHeader{Version: snapshotVersion}); err != nil {
+		return err
+	}
+
+	for _, item := range items {
+		// a pointer to the interface so gob keeps the concrete type, the types must be registered with gob.Register
+		value, err := codec.Encode(&item.value)
+		if err != nil {
+			log.Err(err).Str("key", item.key).Msg("failed to encode value for snapshot, skipping")
+			continue
+		}
+
+		entry := snapshotEntry{
+			Key:            item.key,
+			Value:          value,
+			Expiration:     item.expiration,
+			SoftExpiration: item.softExpiration,
+			Tags:           item.tags,
+		}
+		if err := enc.Encode(entry); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readSnapshot decodes the items in the order they were saved, expired items are skipped
+func readSnapshot(r io.Reader, codec Codec) ([]*cacheItem, error) {
+	if !keepsTypes(codec) {
+		return nil, UntypedCodecErr
+	}
+
+	dec := gob.NewDecoder(r)
+
+	var header snapshotHeader
+	if err := dec.Decode(&header); err != nil {
+		return nil, fmt.Errorf("failed to read snapshot header: %w", err)
+	}
+	if header.Version != snapshotVersion {
+		return nil, fmt.Errorf("unsupported snapshot version %d", header.Version)
+	}
+
+	var items []*cacheItem
+	now := time.Now().UnixNano()
+	for {
+		var entry snapshotEntry
+		if err := dec.Decode(&entry); errors.Is(err, io.EOF) {
+			return items, nil
+		} else if err != nil {
+			return nil, err
+		}
+
+		if entry.Expiration != 0 && now >= entry.Expiration {
+			continue
+		}
+
+		var value interface{}
+		if err := codec.Decode(entry.Value, &value); err != nil {
+			log.Err(err).Str("key", entry.Key).Msg("failed to decode value from snapshot, skipping")
+			continue
+		}
+
+		items = append(items, &cacheItem{
+			key:            entry.Key,
+			value:          value,
+			expiration:     entry.Expiration,
+			softExpiration: entry.SoftExpiration,
+			tags:           entry.Tags,
+			version:        nextVersion(),
+		})
+	}
+}
+
+// SaveFile saves the cache to the file. It writes to a temporary file first so a crash never leaves half a snapshot behind
+func SaveFile(ctx context.Context, c SnapshotCache, path string, codec Codec) error {
+	dir := filepath.Dir(path)
+	f, err := os.CreateTemp(dir, filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	// a no-op once the file was renamed
+	defer os.Remove(f.Name())
+
+	if err := c.Save(ctx, f, codec); err != nil {
+		f.Close()
+		return err
+	}
+	// the data has to be on disk before the rename, otherwise a crash can leave the new name pointing at an empty file
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(f.Name(), path); err != nil {
+		return err
+	}
+
+	// the snapshot is saved either way, this only makes the rename itself survive a crash
+	if err := syncDir(dir); err != nil {
+		log.Err(err).Str("path", path).Msg("failed to sync the snapshot's directory")
+	}
+	return nil
+}
+
+// syncDir flushes the directory's entries, not every OS supports it
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+
+	return d.Sync()
+}
+
+// LoadFile loads a snapshot saved by SaveFile into the cache. A missing file returns an error
+// that matches fs.ErrNotExist, which is expected the first time an app starts
+func LoadFile(ctx context.Context, c SnapshotCache, path string, codec Codec) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	return c.Load(ctx, f, codec)
+}
@@ -0,0 +1,228 @@
+package cache
+
+import (
+	"bytes"
+	"context"
+	"encoding/gob"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type SnapshotTestSuite struct {
+	suite.Suite
+	newCaches func() map[string]SnapshotCache
+}
+
+func TestSnapshotSuite(t *testing.T) {
+	suite.Run(t, new(SnapshotTestSuite))
+}
+
+// SetupTest runs before each test in the suite
+func (s *SnapshotTestSuite) SetupTest() {
+	gob.Register(user{})
+
+	s.newCaches = func() map[string]SnapshotCache {
+		return map[string]SnapshotCache{
+			"in memory": NewInMemoryCache(),
+			"lru":       NewLRUCache(10),
+			"lfu":       NewLFUCache(10),
+			"tinylfu":   NewTinyLFUCache(10),
+			"sharded":   NewShardedCache(2, 20),
+		}
+	}
+}
+
+func (s *SnapshotTestSuite) TestSaveLoad() {
+	restored := s.newCaches()
+	for name, c := range s.newCaches() {
+		s.Run(name, func() {
+			ctx := context.Background()
+			c.SetTTL(ctx, "forever", "value", NoExpiration)
+			c.SetTTL(ctx, "user", user{ID: 42, Name: "meow"}, time.Minute)
+			c.SetTTL(ctx, "expiring", "value", 10*time.Millisecond)
+
+			var buf bytes.Buffer
+			s.NoError(c.Save(ctx, &buf, GobCodec{}))
+
+			// expires between saving and loading
+			time.Sleep(20 * time.Millisecond)
+
+			target := restored[name]
+			s.NoError(target.Load(ctx, &buf, GobCodec{}))
+			s.Equal(uint64(2), target.Size(ctx))
+
+			value, err := target.Get(ctx, "forever")
+			s.NoError(err)
+			s.Equal("value", value)
+
+			value, err = target.Get(ctx, "user")
+			s.NoError(err)
+			s.Equal(user{ID: 42, Name: "meow"}, value)
+
+			_, err = target.Get(ctx, "expiring")
+			s.ErrorIs(err, CacheMissErr)
+		})
+	}
+}
+
+func (s *SnapshotTestSuite) TestSaveLoad_KeepsTypes() {
+	restored := s.newCaches()
+	for name, c := range s.newCaches() {
+		s.Run(name, func() {
+			ctx := context.Background()
+			_, err := c.(AtomicCache).Increment(ctx, "counter", 41, NoExpiration)
+			s.NoError(err)
+			s.NoError(NewTypedCache[user](c).SetTTL(ctx, "user", user{ID: 42, Name: "meow"}, NoExpiration))
+
+			var buf bytes.Buffer
+			s.NoError(c.Save(ctx, &buf, GobCodec{}))
+			target := restored[name]
+			s.NoError(target.Load(ctx, &buf, GobCodec{}))
+
+			n, err := target.(AtomicCache).Increment(ctx, "counter", 1, NoExpiration)
+			s.NoError(err)
+			s.Equal(int64(42), n)
+
+			value, err := NewTypedCache[user](target).Get(ctx, "user")
+			s.NoError(err)
+			s.Equal(user{ID: 42, Name: "meow"}, value)
+		})
+	}
+}
+
+func (s *SnapshotTestSuite) TestSaveLoad_UntypedCodec() {
+	for name, c := range s.newCaches() {
+		s.Run(name, func() {
+			ctx := context.Background()
+			c.SetTTL(ctx, "counter", int64(1), NoExpiration)
+
+			var buf bytes.Buffer
+			s.ErrorIs(c.Save(ctx, &buf, JSONCodec{}), UntypedCodecErr)
+			s.Zero(buf.Len())
+
+			s.NoError(c.Save(ctx, &buf, GobCodec{}))
+			s.ErrorIs(c.Load(ctx, &buf, JSONCodec{}), UntypedCodecErr)
+		})
+	}
+}
+
+func (s *SnapshotTestSuite) TestSaveLoad_KeepsRemainingTTL() {
+	restored := s.newCaches()
+	for name, c := range s.newCaches() {
+		s.Run(name, func() {
+			ctx := context.Background()
+			c.SetTTL(ctx, "key", "value", 30*time.Millisecond)
+
+			var buf bytes.Buffer
+			s.NoError(c.Save(ctx, &buf, GobCodec{}))
+			time.Sleep(10 * time.Millisecond)
+
+			target := restored[name]
+			s.NoError(target.Load(ctx, &buf, GobCodec{}))
+
+			value, err := target.Get(ctx, "key")
+			s.NoError(err)
+			s.Equal("value", value)
+
+			// the ttl didn't start over when it was loaded
+			time.Sleep(25 * time.Millisecond)
+			_, err = target.Get(ctx, "key")
+			s.ErrorIs(err, CacheMissErr)
+		})
+	}
+}
+
+func (s *SnapshotTestSuite) TestSaveLoad_KeepsTags() {
+	restored := s.newCaches()
+	for name, c := range s.newCaches() {
+		s.Run(name, func() {
+			ctx := context.Background()
+			c.(TagCache).SetWithTags(ctx, "orders:42", "orders", time.Minute, "user:42")
+
+			var buf bytes.Buffer
+			s.NoError(c.Save(ctx, &buf, GobCodec{}))
+
+			target := restored[name]
+			s.NoError(target.Load(ctx, &buf, GobCodec{}))
+			s.Equal(uint64(1), target.Size(ctx))
+
+			s.NoError(target.(TagCache).InvalidateTags(ctx, "user:42"))
+			s.Equal(uint64(0), target.Size(ctx))
+		})
+	}
+}
+
+func (s *SnapshotTestSuite) TestSaveLoad_LRUOrder() {
+	ctx := context.Background()
+	lru := NewLRUCache(10)
+	for i := range 10 {
+		lru.SetTTL(ctx, fmt.Sprintf("key:%d", i), i, time.Minute)
+	}
+	// key:0 becomes the most recently used, key:1 the least
+	lru.Get(ctx, "key:0")
+
+	var buf bytes.Buffer
+	s.NoError(lru.Save(ctx, &buf, GobCodec{}))
+
+	restored := NewLRUCache(10)
+	s.NoError(restored.Load(ctx, &buf, GobCodec{}))
+	restored.SetTTL(ctx, "new", "value", time.Minute)
+
+	_, err := restored.Get(ctx, "key:1")
+	s.ErrorIs(err, CacheMissErr)
+	value, err := restored.Get(ctx, "key:0")
+	s.NoError(err)
+	s.Equal(0, value)
+}
+
+func (s *SnapshotTestSuite) TestSave_SkipsUnencodableValues() {
+	ctx := context.Background()
+	lru := NewLRUCache(10)
+	lru.SetTTL(ctx, "func", func() {}, time.Minute)
+	lru.SetTTL(ctx, "key", "value", time.Minute)
+
+	var buf bytes.Buffer
+	s.NoError(lru.Save(ctx, &buf, GobCodec{}))
+
+	restored := NewLRUCache(10)
+	s.NoError(restored.Load(ctx, &buf, GobCodec{}))
+	s.Equal(uint64(1), restored.Size(ctx))
+}
+
+func (s *SnapshotTestSuite) TestLoad_Invalid() {
+	err := NewLRUCache(10).Load(context.Background(), bytes.NewBufferString("not a snapshot"), GobCodec{})
+	s.Error(err)
+
+	var buf bytes.Buffer
+	gob.NewEncoder(&buf).Encode(snapshotHeader{Version: snapshotVersion + 1})
+	err = NewLRUCache(10).Load(context.Background(), &buf, GobCodec{})
+	s.ErrorContains(err, "unsupported snapshot version")
+}
+
+func (s *SnapshotTestSuite) TestSaveFileLoadFile() {
+	ctx := context.Background()
+	path := filepath.Join(s.T().TempDir(), "cache.snapshot")
+
+	err := LoadFile(ctx, NewLRUCache(10), path, GobCodec{})
+	s.ErrorIs(err, fs.ErrNotExist)
+
+	lru := NewLRUCache(10)
+	lru.SetTTL(ctx, "key", "value", time.Minute)
+	s.NoError(SaveFile(ctx, lru, path, GobCodec{}))
+
+	restored := NewLRUCache(10)
+	s.NoError(LoadFile(ctx, restored, path, GobCodec{}))
+	value, err := restored.Get(ctx, "key")
+	s.NoError(err)
+	s.Equal("value", value)
+
+	// only the snapshot is left behind
+	files, err := filepath.Glob(filepath.Join(filepath.Dir(path), "*"))
+	s.NoError(err)
+	s.Equal([]string{path}, files)
+}
@@ -0,0 +1,94 @@
+package cache
+
+import (
+	"context"
+	"reflect"
+	"sync/atomic"
+)
+
+// Stats is a point in time snapshot of a cache's counters
+type Stats struct {
+	Hits        uint64 `json:"hits"`
+	Misses      uint64 `json:"misses"`
+	Sets        uint64 `json:"sets"`
+	Evictions   uint64 `json:"evictions"`   // removed to make room
+	Expirations uint64 `json:"expirations"` // removed because the TTL passed
+	Size        uint64 `json:"size"`        // number of items currently in the cache
+	Bytes       uint64 `json:"bytes"`       // rough estimate of the memory used by keys and values
+}
+
+// HitRatio returns hits / (hits + misses), 0 if there haven't been any reads
+func (s Stats) HitRatio() float64 {
+	total := s.Hits + s.Misses
+	if total == 0 {
+		return 0
+	}
+	return float64(s.Hits) / float64(total)
+}
+
+// StatsCache is a Cache that keeps track of how it is being used
+type StatsCache interface {
+	Cache
+
+	// Stats returns a snapshot of the cache's counters
+	Stats(ctx context.Context) Stats
+}
+
+// counters are the running totals shared by the backends, safe for concurrent use
+type counters struct {
+	hits        atomic.Uint64
+	misses      atomic.Uint64
+	sets        atomic.Uint64
+	evictions   atomic.Uint64
+	expirations atomic.Uint64
+}
+
+func (c *counters) hit(found bool) {
+	if found {
+		c.hits.Add(1)
+	} else {
+		c.misses.Add(1)
+	}
+}
+
+func (c *counters) evicted(evictions []eviction) {
+	for _, e := range evictions {
+		switch e.reason {
+		case ExpiredEviction:
+			c.expirations.Add(1)
+		case CapacityEviction:
+			c.evictions.Add(1)
+		}
+	}
+}
+
+func (c *counters) snapshot() Stats {
+	return Stats{
+		Hits:        c.hits.Load(),
+		Misses:      c.misses.Load(),
+		Sets:        c.sets.Load(),
+		Evictions:   c.evictions.Load(),
+		Expirations: c.expirations.Load(),
+	}
+}
+
+// itemOverhead is a rough guess at what the cache spends per item on top of the key and value
+const itemOverhead = 64
+
+// estimateSize guesses how many bytes an item takes up. Strings and bytes are exact,
+// everything else is the size of the type itself without following pointers
+func estimateSize(key string, value interface{}) uint64 {
+	size := uint64(len(key)) + itemOverhead
+
+	switch v := value.(type) {
+	case nil:
+	case string:
+		size += uint64(len(v))
+	case []byte:
+		size += uint64(len(v))
+	default:
+		size += uint64(reflect.TypeOf(v).Size())
+	}
+
+	return size
+}
@@ -0,0 +1,81 @@
+package cache
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type StatsTestSuite struct {
+	suite.Suite
+}
+
+func TestStatsSuite(t *testing.T) {
+	suite.Run(t, new(StatsTestSuite))
+}
+
+func (s *StatsTestSuite) TestStats_Counters() {
+	caches := map[string]StatsCache{
+		"in memory": NewInMemoryCache(),
+		"lru":       NewLRUCache(10),
+	}
+
+	for name, c := range caches {
+		s.Run(name, func() {
+			c.SetTTL(context.Background(), "hit", "value", time.Minute)
+			c.SetTTL(context.Background(), "expired", "value", time.Millisecond)
+			time.Sleep(2 * time.Millisecond)
+
+			c.Get(context.Background(), "hit")
+			c.Get(context.Background(), "hit")
+			c.Get(context.Background(), "expired")
+			c.Get(context.Background(), "does_not_exist")
+
+			stats := c.Stats(context.Background())
+			s.Equal(uint64(2), stats.Hits)
+			s.Equal(uint64(2), stats.Misses)
+			s.Equal(uint64(2), stats.Sets)
+			s.Equal(uint64(1), stats.Expirations)
+			s.Equal(uint64(0), stats.Evictions)
+			s.Equal(uint64(1), stats.Size)
+			s.Equal(estimateSize("hit", "value"), stats.Bytes)
+			s.Equal(0.5, stats.HitRatio())
+		})
+	}
+}
+
+func (s *StatsTestSuite) TestStats_LRUEvictions() {
+	lru := NewLRUCache(10)
+	for i := range 15 {
+		lru.SetTTL(context.Background(), strconv.Itoa(i), i, time.Minute)
+	}
+
+	stats := lru.Stats(context.Background())
+	s.Equal(uint64(15), stats.Sets)
+	s.Equal(uint64(5), stats.Evictions)
+	s.Equal(uint64(10), stats.Size)
+}
+
+func (s *StatsTestSuite) TestStats_HitRatioNoReads() {
+	s.Equal(float64(0), Stats{}.HitRatio())
+}
+
+func (s *StatsTestSuite) TestEstimateSize() {
+	s.Equal(uint64(itemOverhead+3+5), estimateSize("key", "value"))
+	s.Equal(uint64(itemOverhead+3+4), estimateSize("key", []byte("1234")))
+	s.Equal(uint64(itemOverhead+3+8), estimateSize("key", int64(1)))
+	s.Equal(uint64(itemOverhead+3), estimateSize("key", nil))
+}
+
+func (s *StatsTestSuite) TestParseInfo() {
+	info := "# Stats\r\nexpired_keys:12\r\nevicted_keys:3\r\nrole:master\r\n\r\n# Memory\r\nused_memory:1024\r\n"
+
+	fields := parseInfo(info)
+	s.Equal(uint64(12), fields["expired_keys"])
+	s.Equal(uint64(3), fields["evicted_keys"])
+	s.Equal(uint64(1024), fields["used_memory"])
+	s.NotContains(fields, "role")
+}
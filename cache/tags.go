@@ -0,0 +1,42 @@
+package cache
+
+// tagIndex tracks the keys set with each tag for the in-process caches.
+// It isn't safe for concurrent use, callers must hold the cache's lock
+type tagIndex map[string]map[string]struct{}
+
+// add indexes the item under each of its tags
+func (t tagIndex) add(item *cacheItem) {
+	for _, tag := range item.tags {
+		keys, found := t[tag]
+		if !found {
+			keys = make(map[string]struct{})
+			t[tag] = keys
+		}
+		keys[item.key] = struct{}{}
+	}
+}
+
+// remove drops the item from each of its tags, tags without keys are removed
+func (t tagIndex) remove(item *cacheItem) {
+	for _, tag := range item.tags {
+		delete(t[tag], item.key)
+		if len(t[tag]) == 0 {
+			delete(t, tag)
+		}
+	}
+}
+
+// keys returns every key set with any of the tags
+func (t tagIndex) keys(tags []string) []string {
+	var keys []string
+	seen := make(map[string]struct{})
+	for _, tag := range tags {
+		for key := range t[tag] {
+			if _, found := seen[key]; !found {
+				seen[key] = struct{}{}
+				keys = append(keys, key)
+			}
+		}
+	}
+	return keys
+}
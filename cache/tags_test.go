@@ -0,0 +1,135 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type TagTestSuite struct {
+	suite.Suite
+	caches map[string]TagCache
+}
+
+func TestTagSuite(t *testing.T) {
+	suite.Run(t, new(TagTestSuite))
+}
+
+// SetupTest runs before each test in the suite
+func (s *TagTestSuite) SetupTest() {
+	s.caches = map[string]TagCache{
+		"in memory": NewInMemoryCache(),
+		"lru":       NewLRUCache(10),
+		"lfu":       NewLFUCache(10),
+		"tinylfu":   NewTinyLFUCache(10),
+		"sharded":   NewShardedCache(2, 20),
+	}
+}
+
+func (s *TagTestSuite) TestInvalidateTags() {
+	for name, c := range s.caches {
+		s.Run(name, func() {
+			ctx := context.Background()
+			c.SetWithTags(ctx, "user:42", "user", time.Minute, "user:42")
+			c.SetWithTags(ctx, "orders:42", "orders", time.Minute, "user:42", "orders")
+			c.SetWithTags(ctx, "orders:7", "orders", time.Minute, "user:7", "orders")
+			c.SetTTL(ctx, "untagged", "value", time.Minute)
+
+			s.NoError(c.InvalidateTags(ctx, "user:42"))
+
+			_, err := c.Get(ctx, "user:42")
+			s.ErrorIs(err, CacheMissErr)
+			_, err = c.Get(ctx, "orders:42")
+			s.ErrorIs(err, CacheMissErr)
+
+			value, err := c.Get(ctx, "orders:7")
+			s.NoError(err)
+			s.Equal("orders", value)
+			value, err = c.Get(ctx, "untagged")
+			s.NoError(err)
+			s.Equal("value", value)
+		})
+	}
+}
+
+func (s *TagTestSuite) TestInvalidateTags_ManyTags() {
+	for name, c := range s.caches {
+		s.Run(name, func() {
+			ctx := context.Background()
+			c.SetWithTags(ctx, "a", "a", time.Minute, "one")
+			c.SetWithTags(ctx, "b", "b", time.Minute, "two")
+			c.SetWithTags(ctx, "c", "c", time.Minute, "three")
+
+			s.NoError(c.InvalidateTags(ctx, "one", "two", "unknown"))
+			s.Equal(uint64(1), c.Size(ctx))
+		})
+	}
+}
+
+func (s *TagTestSuite) TestSetWithTags_ReplacesTags() {
+	for name, c := range s.caches {
+		s.Run(name, func() {
+			ctx := context.Background()
+			c.SetWithTags(ctx, "key", "old", time.Minute, "old")
+			c.SetWithTags(ctx, "key", "new", time.Minute, "new")
+
+			s.NoError(c.InvalidateTags(ctx, "old"))
+			value, err := c.Get(ctx, "key")
+			s.NoError(err)
+			s.Equal("new", value)
+
+			// a plain set drops the tags too
+			c.SetTTL(ctx, "key", "plain", time.Minute)
+			s.NoError(c.InvalidateTags(ctx, "new"))
+			value, err = c.Get(ctx, "key")
+			s.NoError(err)
+			s.Equal("plain", value)
+		})
+	}
+}
+
+func (s *TagTestSuite) TestSetWithTags_Expires() {
+	for name, c := range s.caches {
+		s.Run(name, func() {
+			ctx := context.Background()
+			c.SetWithTags(ctx, "key", "value", time.Millisecond, "tag")
+			time.Sleep(5 * time.Millisecond)
+
+			_, err := c.Get(ctx, "key")
+			s.ErrorIs(err, CacheMissErr)
+			s.NoError(c.InvalidateTags(ctx, "tag"))
+		})
+	}
+}
+
+func (s *TagTestSuite) TestInvalidateTags_AfterPurge() {
+	for name, c := range s.caches {
+		s.Run(name, func() {
+			ctx := context.Background()
+			c.SetWithTags(ctx, "key", "value", time.Minute, "tag")
+			c.Purge(ctx)
+
+			s.NoError(c.InvalidateTags(ctx, "tag"))
+			s.Equal(uint64(0), c.Size(ctx))
+		})
+	}
+}
+
+func (s *TagTestSuite) TestTagIndex_CleanedOnEviction() {
+	ctx := context.Background()
+	lru := NewLRUCache(10)
+	for i := range 100 {
+		lru.SetWithTags(ctx, fmt.Sprintf("key:%d", i), i, time.Minute, "all", fmt.Sprintf("tag:%d", i))
+	}
+
+	// only the items still in the cache are indexed
+	s.Len(lru.tags, 11)
+	s.Len(lru.tags["all"], 10)
+
+	lru.InvalidateTags(ctx, "all")
+	s.Empty(lru.tags)
+	s.Equal(uint64(0), lru.Size(ctx))
+}
@@ -0,0 +1,335 @@
+package cache
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// DefaultL1TTL bounds how long a value read from L2 stays in L1,
+// so a lost invalidation can only leave an instance stale for so long
+const DefaultL1TTL = time.Minute
+
+// ttlCache is implemented by caches that can tell how long a key has left, so L1 never keeps it longer than L2
+type ttlCache interface {
+	// getWithTTL retrieves data given a key and how long until it expires
+	getWithTTL(ctx context.Context, key string) (interface{}, time.Duration, error)
+}
+
+// InvalidationBus broadcasts invalidations to every instance sharing the same L2 cache
+type InvalidationBus interface {
+	// Publish sends the message to every subscriber, including ourselves
+	Publish(ctx context.Context, message []byte) error
+
+	// Subscribe calls the handler for every published message until the returned function is called
+	Subscribe(ctx context.Context, handler func(message []byte)) (func() error, error)
+}
+
+// invalidation is the message sent over the InvalidationBus
+type invalidation struct {
+	Source string   `json:"source"`
+	Keys   []string `json:"keys,omitempty"`
+	Prefix string   `json:"prefix,omitempty"`
+	Purge  bool     `json:"purge,omitempty"`
+}
+
+// TieredOption configures the tiered cache
+type TieredOption func(*tieredCache)
+
+// WithL1TTL caps how long items live in L1, NoExpiration removes the cap
+func WithL1TTL(ttl time.Duration) TieredOption {
+	return func(c *tieredCache) {
+		c.l1TTL = ttl
+	}
+}
+
+// tieredCache is a near-cache, a small per-process L1 in front of a shared L2.
+// Reads fall through L1 -> L2 and writes go to both. Other instances drop their L1 copy
+// when they are told about the write over the InvalidationBus
+type tieredCache struct {
+	l1          Cache
+	l2          Cache
+	l1TTL       time.Duration
+	bus         InvalidationBus
+	id          string
+	unsubscribe func() error
+	loads       flightGroup
+}
+
+// NewTieredCache creates a cache that reads from l1 first and falls back to l2.
+// The bus is optional, without it other instances only see writes once their L1 copy expires
+func NewTieredCache(ctx context.Context, l1, l2 Cache, bus InvalidationBus, opts ...TieredOption) (*tieredCache, error) {
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return nil, err
+	}
+
+	c := &tieredCache{
+		l1:    l1,
+		l2:    l2,
+		l1TTL: DefaultL1TTL,
+		bus:   bus,
+		id:    hex.EncodeToString(id),
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	if bus != nil {
+		unsubscribe, err := bus.Subscribe(ctx, c.handleInvalidation)
+		if err != nil {
+			log.Err(err).Msg("failed to subscribe to cache invalidations")
+			return nil, err
+		}
+		c.unsubscribe = unsubscribe
+	}
+
+	return c, nil
+}
+
+// Get retrieves data given a key from L1, falling back to L2
+func (c *tieredCache) Get(ctx context.Context, key string) (interface{}, error) {
+	value, err := c.l1.Get(ctx, key)
+	if err == nil {
+		return value, nil
+	}
+
+	// without the remaining ttl of L2, L1 keeps it for the L1 TTL
+	ttl := NoExpiration
+	if tc, ok := c.l2.(ttlCache); ok {
+		value, ttl, err = tc.getWithTTL(ctx, key)
+	} else {
+		value, err = c.l2.Get(ctx, key)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := c.l1.SetTTL(ctx, key, value, c.capTTL(ttl)); err != nil {
+		log.Err(err).Str("key", key).Msg("failed to fill L1 cache")
+	}
+
+	return value, nil
+}
+
+// Set adds the value for a given key to both tiers, ttl is in seconds
+func (c *tieredCache) Set(ctx context.Context, key string, value interface{}, ttl int) error {
+	return c.SetTTL(ctx, key, value, seconds(ttl))
+}
+
+// SetTTL adds the value for a given key to both tiers and invalidates it on other instances
+func (c *tieredCache) SetTTL(ctx context.Context, key string, value interface{}, ttl time.Duration) error {
+	if err := c.l2.SetTTL(ctx, key, value, ttl); err != nil {
+		return err
+	}
+
+	if err := c.l1.SetTTL(ctx, key, value, c.capTTL(ttl)); err != nil {
+		log.Err(err).Str("key", key).Msg("failed to set L1 cache")
+	}
+
+	c.publish(ctx, invalidation{Keys: []string{key}})
+	return nil
+}
+
+// GetOrLoad retrieves data given a key, calling the loader once on a miss in both tiers
+func (c *tieredCache) GetOrLoad(ctx context.Context, key string, ttl time.Duration, loader LoaderFunc) (interface{}, error) {
+	return getOrLoad(ctx, c, &c.loads, key, ttl, loader)
+}
+
+// GetMany retrieves data for all the keys from L1, the misses are fetched from L2 in a single batch
+func (c *tieredCache) GetMany(ctx context.Context, keys []string) (map[string]interface{}, error) {
+	values, err := GetMany(ctx, c.l1, keys)
+	if err != nil {
+		return nil, err
+	}
+
+	var missed []string
+	for _, key := range keys {
+		if _, found := values[key]; !found {
+			missed = append(missed, key)
+		}
+	}
+	if len(missed) == 0 {
+		return values, nil
+	}
+
+	fetched, err := GetMany(ctx, c.l2, missed)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(fetched) > 0 {
+		if err := SetMany(ctx, c.l1, fetched, c.l1TTL); err != nil {
+			log.Err(err).Msg("failed to fill L1 cache")
+		}
+	}
+
+	for key, value := range fetched {
+		values[key] = value
+	}
+	return values, nil
+}
+
+// SetMany adds all the values to both tiers and invalidates them on other instances
+func (c *tieredCache) SetMany(ctx context.Context, items map[string]interface{}, ttl time.Duration) error {
+	if err := SetMany(ctx, c.l2, items, ttl); err != nil {
+		return err
+	}
+
+	if err := SetMany(ctx, c.l1, items, c.capTTL(ttl)); err != nil {
+		log.Err(err).Msg("failed to set L1 cache")
+	}
+
+	keys := make([]string, 0, len(items))
+	for key := range items {
+		keys = append(keys, key)
+	}
+	c.publish(ctx, invalidation{Keys: keys})
+	return nil
+}
+
+// Delete removes the item from both tiers and invalidates it on other instances
+func (c *tieredCache) Delete(ctx context.Context, key string) error {
+	return c.DeleteMany(ctx, []string{key})
+}
+
+// DeleteMany removes all the items from both tiers and invalidates them on other instances
+func (c *tieredCache) DeleteMany(ctx context.Context, keys []string) error {
+	if err := DeleteMany(ctx, c.l2, keys); err != nil {
+		return err
+	}
+
+	if err := DeleteMany(ctx, c.l1, keys); err != nil {
+		log.Err(err).Msg("failed to delete from L1 cache")
+	}
+
+	c.publish(ctx, invalidation{Keys: keys})
+	return nil
+}
+
+// Purge clear all items in both tiers and on every other instance's L1
+func (c *tieredCache) Purge(ctx context.Context) {
+	c.l2.Purge(ctx)
+	c.l1.Purge(ctx)
+	c.publish(ctx, invalidation{Purge: true})
+}
+
+// DeletePrefix removes every item whose key starts with the prefix from both tiers and on other instances
+func (c *tieredCache) DeletePrefix(ctx context.Context, prefix string) error {
+	if err := deletePrefix(ctx, c.l2, prefix); err != nil {
+		return err
+	}
+
+	if err := deletePrefix(ctx, c.l1, prefix); err != nil {
+		log.Err(err).Msg("failed to delete prefix from L1 cache")
+	}
+
+	c.publish(ctx, invalidation{Prefix: prefix})
+	return nil
+}
+
+// SizePrefix returns the number of items in L2 whose key starts with the prefix
+func (c *tieredCache) SizePrefix(ctx context.Context, prefix string) uint64 {
+	return sizePrefix(ctx, c.l2, prefix)
+}
+
+// Size returns the number of elements in L2
+func (c *tieredCache) Size(ctx context.Context) uint64 {
+	return c.l2.Size(ctx)
+}
+
+// Stats combines the counters of both tiers. A read hits when either tier has the key and misses when L2 doesn't,
+// sets, size and bytes are L2's while the evictions of both tiers are added up. Tiers that don't keep stats count as 0
+func (c *tieredCache) Stats(ctx context.Context) Stats {
+	var l1, l2 Stats
+	if sc, ok := c.l1.(StatsCache); ok {
+		l1 = sc.Stats(ctx)
+	}
+	if sc, ok := c.l2.(StatsCache); ok {
+		l2 = sc.Stats(ctx)
+	}
+
+	return Stats{
+		Hits:        l1.Hits + l2.Hits,
+		Misses:      l2.Misses,
+		Sets:        l2.Sets,
+		Evictions:   l1.Evictions + l2.Evictions,
+		Expirations: l1.Expirations + l2.Expirations,
+		Size:        l2.Size,
+		Bytes:       l2.Bytes,
+	}
+}
+
+// Close stops listening for invalidations
+func (c *tieredCache) Close() error {
+	if c.unsubscribe == nil {
+		return nil
+	}
+	return c.unsubscribe()
+}
+
+func (c *tieredCache) requiresEncoding() bool {
+	return requiresEncoding(c.l1) || requiresEncoding(c.l2)
+}
+
+// capTTL returns the ttl to use in L1 so it never outlives the L1 TTL
+func (c *tieredCache) capTTL(ttl time.Duration) time.Duration {
+	if c.l1TTL == NoExpiration {
+		return ttl
+	}
+	if ttl == NoExpiration || ttl > c.l1TTL {
+		return c.l1TTL
+	}
+	return ttl
+}
+
+func (c *tieredCache) publish(ctx context.Context, msg invalidation) {
+	if c.bus == nil {
+		return
+	}
+
+	msg.Source = c.id
+	data, err := json.Marshal(msg)
+	if err != nil {
+		log.Err(err).Msg("failed to marshal cache invalidation")
+		return
+	}
+
+	if err := c.bus.Publish(ctx, data); err != nil {
+		log.Err(err).Msg("failed to publish cache invalidation")
+	}
+}
+
+func (c *tieredCache) handleInvalidation(data []byte) {
+	var msg invalidation
+	if err := json.Unmarshal(data, &msg); err != nil {
+		log.Err(err).Msg("failed to unmarshal cache invalidation")
+		return
+	}
+
+	// we already updated our own L1 when we published this
+	if msg.Source == c.id {
+		return
+	}
+
+	ctx := context.Background()
+	if msg.Purge {
+		c.l1.Purge(ctx)
+		return
+	}
+
+	if msg.Prefix != "" {
+		if err := deletePrefix(ctx, c.l1, msg.Prefix); err != nil {
+			log.Err(err).Msg("failed to invalidate L1 cache")
+		}
+		return
+	}
+
+	if err := DeleteMany(ctx, c.l1, msg.Keys); err != nil {
+		log.Err(err).Msg("failed to invalidate L1 cache")
+	}
+}
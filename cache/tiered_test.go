@@ -0,0 +1,245 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+// memoryBus is an InvalidationBus that delivers messages synchronously within the process
+type memoryBus struct {
+	mu       sync.Mutex
+	handlers map[int]func(message []byte)
+	next     int
+}
+
+func newMemoryBus() *memoryBus {
+	return &memoryBus{handlers: make(map[int]func(message []byte))}
+}
+
+func (b *memoryBus) Publish(ctx context.Context, message []byte) error {
+	b.mu.Lock()
+	handlers := make([]func(message []byte), 0, len(b.handlers))
+	for _, handler := range b.handlers {
+		handlers = append(handlers, handler)
+	}
+	b.mu.Unlock()
+
+	for _, handler := range handlers {
+		handler(message)
+	}
+	return nil
+}
+
+func (b *memoryBus) Subscribe(ctx context.Context, handler func(message []byte)) (func() error, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	id := b.next
+	b.next++
+	b.handlers[id] = handler
+
+	return func() error {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		delete(b.handlers, id)
+		return nil
+	}, nil
+}
+
+type TieredTestSuite struct {
+	suite.Suite
+	l2     *InMemoryCache
+	bus    *memoryBus
+	first  *tieredCache
+	second *tieredCache
+}
+
+func TestTieredSuite(t *testing.T) {
+	suite.Run(t, new(TieredTestSuite))
+}
+
+// SetupTest runs before each test in the suite
+func (s *TieredTestSuite) SetupTest() {
+	s.l2 = NewInMemoryCache()
+	s.bus = newMemoryBus()
+
+	var err error
+	s.first, err = NewTieredCache(context.Background(), NewLRUCache(10), s.l2, s.bus)
+	s.Require().NoError(err)
+	s.second, err = NewTieredCache(context.Background(), NewLRUCache(10), s.l2, s.bus)
+	s.Require().NoError(err)
+}
+
+// TearDownTest runs after each test in the suite
+func (s *TieredTestSuite) TearDownTest() {
+	s.first.Close()
+	s.second.Close()
+}
+
+func (s *TieredTestSuite) TestTiered_SetWritesBothTiers() {
+	err := s.first.SetTTL(context.Background(), "key", "value", time.Minute)
+	s.NoError(err)
+
+	value, err := s.first.l1.Get(context.Background(), "key")
+	s.NoError(err)
+	s.Equal("value", value)
+
+	value, err = s.l2.Get(context.Background(), "key")
+	s.NoError(err)
+	s.Equal("value", value)
+}
+
+func (s *TieredTestSuite) TestTiered_ReadFallsThroughAndFillsL1() {
+	s.l2.SetTTL(context.Background(), "key", "value", time.Minute)
+
+	value, err := s.second.Get(context.Background(), "key")
+	s.NoError(err)
+	s.Equal("value", value)
+
+	value, err = s.second.l1.Get(context.Background(), "key")
+	s.NoError(err)
+	s.Equal("value", value)
+}
+
+func (s *TieredTestSuite) TestTiered_Miss() {
+	_, err := s.first.Get(context.Background(), "does_not_exist")
+	s.True(errors.Is(err, CacheMissErr))
+}
+
+func (s *TieredTestSuite) TestTiered_SetInvalidatesOtherInstances() {
+	s.first.SetTTL(context.Background(), "key", "old", time.Minute)
+	s.second.Get(context.Background(), "key") // second now has "old" in its L1
+
+	s.first.SetTTL(context.Background(), "key", "new", time.Minute)
+
+	_, err := s.second.l1.Get(context.Background(), "key")
+	s.True(errors.Is(err, CacheMissErr))
+
+	value, err := s.second.Get(context.Background(), "key")
+	s.NoError(err)
+	s.Equal("new", value)
+
+	// our own L1 isn't invalidated by our own message
+	value, err = s.first.l1.Get(context.Background(), "key")
+	s.NoError(err)
+	s.Equal("new", value)
+}
+
+func (s *TieredTestSuite) TestTiered_DeleteInvalidatesOtherInstances() {
+	s.first.SetTTL(context.Background(), "key", "value", time.Minute)
+	s.second.Get(context.Background(), "key")
+
+	err := s.first.Delete(context.Background(), "key")
+	s.NoError(err)
+
+	_, err = s.second.Get(context.Background(), "key")
+	s.True(errors.Is(err, CacheMissErr))
+}
+
+func (s *TieredTestSuite) TestTiered_PurgeInvalidatesOtherInstances() {
+	s.first.SetTTL(context.Background(), "key", "value", time.Minute)
+	s.second.Get(context.Background(), "key")
+
+	s.first.Purge(context.Background())
+
+	s.Equal(uint64(0), s.second.l1.Size(context.Background()))
+	s.Equal(uint64(0), s.second.Size(context.Background()))
+}
+
+func (s *TieredTestSuite) TestTiered_DeletePrefixInvalidatesOtherInstances() {
+	s.first.SetTTL(context.Background(), "orders:1", 1, time.Minute)
+	s.first.SetTTL(context.Background(), "users:1", 1, time.Minute)
+	s.second.Get(context.Background(), "orders:1")
+	s.second.Get(context.Background(), "users:1")
+
+	err := s.first.DeletePrefix(context.Background(), "orders:")
+	s.NoError(err)
+
+	_, err = s.second.Get(context.Background(), "orders:1")
+	s.True(errors.Is(err, CacheMissErr))
+	_, err = s.second.l1.Get(context.Background(), "users:1")
+	s.NoError(err)
+	s.Equal(uint64(1), s.second.SizePrefix(context.Background(), "users:"))
+}
+
+func (s *TieredTestSuite) TestTiered_GetMany() {
+	s.first.SetTTL(context.Background(), "1", 1, time.Minute)
+	s.l2.SetTTL(context.Background(), "2", 2, time.Minute)
+
+	values, err := s.first.GetMany(context.Background(), []string{"1", "2", "3"})
+	s.NoError(err)
+	s.Equal(map[string]interface{}{"1": 1, "2": 2}, values)
+
+	value, err := s.first.l1.Get(context.Background(), "2")
+	s.NoError(err)
+	s.Equal(2, value)
+}
+
+func (s *TieredTestSuite) TestTiered_L1TTLIsCapped() {
+	tiered, err := NewTieredCache(context.Background(), NewLRUCache(10), s.l2, nil, WithL1TTL(time.Millisecond))
+	s.Require().NoError(err)
+
+	tiered.SetTTL(context.Background(), "key", "value", NoExpiration)
+	time.Sleep(2 * time.Millisecond)
+
+	_, err = tiered.l1.Get(context.Background(), "key")
+	s.True(errors.Is(err, CacheMissErr))
+
+	value, err := tiered.Get(context.Background(), "key")
+	s.NoError(err)
+	s.Equal("value", value)
+}
+
+func (s *TieredTestSuite) TestTiered_Stats() {
+	ctx := context.Background()
+	s.NoError(s.first.SetTTL(ctx, "key", "value", time.Minute))
+
+	// from L1
+	_, err := s.first.Get(ctx, "key")
+	s.NoError(err)
+	// from L2, which fills the second L1
+	_, err = s.second.Get(ctx, "key")
+	s.NoError(err)
+	_, err = s.second.Get(ctx, "key")
+	s.NoError(err)
+	_, err = s.second.Get(ctx, "does_not_exist")
+	s.ErrorIs(err, CacheMissErr)
+
+	stats := s.second.Stats(ctx)
+	s.Equal(uint64(2), stats.Hits)
+	s.Equal(uint64(1), stats.Misses)
+	s.Equal(uint64(1), stats.Sets)
+	s.Equal(uint64(1), stats.Size)
+
+	var _ StatsCache = s.first
+}
+
+func (s *TieredTestSuite) TestTiered_L1FillKeepsL2TTL() {
+	ctx := context.Background()
+	s.l2.SetTTL(ctx, "key", "value", 20*time.Millisecond)
+
+	_, err := s.first.Get(ctx, "key")
+	s.NoError(err)
+	_, err = s.first.l1.Get(ctx, "key")
+	s.NoError(err)
+
+	// gone from both tiers even though the L1 TTL is a minute
+	time.Sleep(30 * time.Millisecond)
+	_, err = s.first.l1.Get(ctx, "key")
+	s.ErrorIs(err, CacheMissErr)
+	_, err = s.first.Get(ctx, "key")
+	s.ErrorIs(err, CacheMissErr)
+}
+
+func (s *TieredTestSuite) TestTiered_RequiresEncoding() {
+	s.False(s.first.requiresEncoding())
+
+	tiered, err := NewTieredCache(context.Background(), NewLRUCache(10), &redisCache{}, nil)
+	s.Require().NoError(err)
+	s.True(tiered.requiresEncoding())
+}
@@ -0,0 +1,235 @@
+package cache
+
+import "container/list"
+
+type tinyLFUSegment uint8
+
+const (
+	windowSegment tinyLFUSegment = iota
+	probationSegment
+	protectedSegment
+)
+
+// tinyLFUNode is where an item lives in the tinyLFUPolicy
+type tinyLFUNode struct {
+	segment tinyLFUSegment
+	element *list.Element
+}
+
+// tinyLFUPolicy is W-TinyLFU. New items go into a small LRU window. Items leaving the window only make it into the
+// main segmented LRU if they've been used more often than the item they would replace, according to a frequency sketch.
+// That way a scan of one off keys can't flush the hot set
+type tinyLFUPolicy struct {
+	sketch *countMinSketch
+
+	window    *list.List
+	probation *list.List
+	protected *list.List
+
+	windowCap    int
+	mainCap      int
+	protectedCap int
+}
+
+// NewTinyLFUCache creates a W-TinyLFU cache that holds up to capacity items.
+// It keeps a hit ratio close to LFU for skewed traffic while adapting to changes like LRU
+func NewTinyLFUCache(capacity uint64, opts ...Option) *policyCache {
+	capacity = validCapacity(capacity)
+
+	// 1% window, the rest split 20/80 between probation and protected
+	windowCap := int(capacity / 100)
+	if windowCap < 1 {
+		windowCap = 1
+	}
+	mainCap := int(capacity) - windowCap
+
+	return newPolicyCache(&tinyLFUPolicy{
+		sketch:       newCountMinSketch(capacity),
+		window:       list.New(),
+		probation:    list.New(),
+		protected:    list.New(),
+		windowCap:    windowCap,
+		mainCap:      mainCap,
+		protectedCap: mainCap * 8 / 10,
+	}, opts)
+}
+
+func (p *tinyLFUPolicy) add(item *cacheItem) []*cacheItem {
+	p.sketch.increment(item.key)
+
+	item.node = &tinyLFUNode{
+		segment: windowSegment,
+		element: p.window.PushFront(item),
+	}
+
+	if p.window.Len() <= p.windowCap {
+		return nil
+	}
+
+	// the window is full so its oldest item tries to get into the main segments
+	candidate := p.window.Remove(p.window.Back()).(*cacheItem)
+
+	if p.probation.Len()+p.protected.Len() < p.mainCap {
+		p.push(candidate, probationSegment)
+		return nil
+	}
+
+	victimElement := p.probation.Back()
+	if victimElement == nil {
+		victimElement = p.protected.Back()
+	}
+	victim := victimElement.Value.(*cacheItem)
+
+	// admission, only replace the victim if the candidate has been used more often
+	if p.sketch.estimate(candidate.key) > p.sketch.estimate(victim.key) {
+		p.remove(victim)
+		p.push(candidate, probationSegment)
+		return []*cacheItem{victim}
+	}
+
+	candidate.node = nil
+	return []*cacheItem{candidate}
+}
+
+func (p *tinyLFUPolicy) hit(item *cacheItem) {
+	p.sketch.increment(item.key)
+
+	node := item.node.(*tinyLFUNode)
+	switch node.segment {
+	case windowSegment:
+		p.window.MoveToFront(node.element)
+	case protectedSegment:
+		p.protected.MoveToFront(node.element)
+	case probationSegment:
+		// used again while on probation, promote it and demote the oldest protected item if needed
+		p.probation.Remove(node.element)
+		p.push(item, protectedSegment)
+
+		if p.protected.Len() > p.protectedCap {
+			demoted := p.protected.Remove(p.protected.Back()).(*cacheItem)
+			p.push(demoted, probationSegment)
+		}
+	}
+}
+
+func (p *tinyLFUPolicy) replace(item *cacheItem, oldWeight uint64) []*cacheItem {
+	p.hit(item)
+	return nil
+}
+
+func (p *tinyLFUPolicy) remove(item *cacheItem) {
+	node, ok := item.node.(*tinyLFUNode)
+	if !ok {
+		return
+	}
+
+	p.segment(node.segment).Remove(node.element)
+	item.node = nil
+}
+
+func (p *tinyLFUPolicy) reset() {
+	p.window = list.New()
+	p.probation = list.New()
+	p.protected = list.New()
+	p.sketch.reset()
+}
+
+func (p *tinyLFUPolicy) push(item *cacheItem, segment tinyLFUSegment) {
+	item.node = &tinyLFUNode{
+		segment: segment,
+		element: p.segment(segment).PushFront(item),
+	}
+}
+
+func (p *tinyLFUPolicy) segment(segment tinyLFUSegment) *list.List {
+	switch segment {
+	case probationSegment:
+		return p.probation
+	case protectedSegment:
+		return p.protected
+	default:
+		return p.window
+	}
+}
+
+const (
+	sketchDepth      = 4
+	sketchMaxCounter = 15 // 4 bit counters are enough to tell hot from cold
+)
+
+// countMinSketch estimates how often a key was seen using a fixed amount of memory.
+// Counters are halved every sampleSize increments so old popularity fades away
+type countMinSketch struct {
+	rows       [sketchDepth][]uint8
+	mask       uint64
+	additions  uint64
+	sampleSize uint64
+}
+
+func newCountMinSketch(capacity uint64) *countMinSketch {
+	// 4 counters per cached item keeps collisions low, which costs 16 bytes per item over the 4 rows
+	width := nextPowerOfTwo(capacity * 4)
+	if width < 16 {
+		width = 16
+	}
+
+	s := &countMinSketch{
+		mask:       width - 1,
+		sampleSize: capacity * 10,
+	}
+	for i := range s.rows {
+		s.rows[i] = make([]uint8, width)
+	}
+	return s
+}
+
+func (s *countMinSketch) increment(key string) {
+	h1, h2 := s.hashes(key)
+	for i := range s.rows {
+		index := (h1 + uint64(i)*h2) & s.mask
+		if s.rows[i][index] < sketchMaxCounter {
+			s.rows[i][index]++
+		}
+	}
+
+	s.additions++
+	if s.additions >= s.sampleSize {
+		s.age()
+	}
+}
+
+func (s *countMinSketch) estimate(key string) uint8 {
+	h1, h2 := s.hashes(key)
+
+	lowest := uint8(sketchMaxCounter)
+	for i := range s.rows {
+		if count := s.rows[i][(h1+uint64(i)*h2)&s.mask]; count < lowest {
+			lowest = count
+		}
+	}
+	return lowest
+}
+
+// age halves every counter
+func (s *countMinSketch) age() {
+	for i := range s.rows {
+		for j := range s.rows[i] {
+			s.rows[i][j] >>= 1
+		}
+	}
+	s.additions /= 2
+}
+
+func (s *countMinSketch) reset() {
+	for i := range s.rows {
+		clear(s.rows[i])
+	}
+	s.additions = 0
+}
+
+// hashes derives the two hashes used for double hashing the rows
+func (s *countMinSketch) hashes(key string) (uint64, uint64) {
+	hash := fnv64a(key)
+	// the second hash must be odd so every row index is reachable
+	return hash, (hash>>32 | hash<<32) | 1
+}
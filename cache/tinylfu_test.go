@@ -0,0 +1,129 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type TinyLFUTestSuite struct {
+	suite.Suite
+	cache *policyCache
+}
+
+func TestTinyLFUTestSuite(t *testing.T) {
+	suite.Run(t, new(TinyLFUTestSuite))
+}
+
+// SetupTest runs before each test in the suite
+func (s *TinyLFUTestSuite) SetupTest() {
+	s.cache = NewTinyLFUCache(100)
+}
+
+// TearDownTest runs after each test in the suite
+func (s *TinyLFUTestSuite) TearDownTest() {
+	s.cache.Close()
+}
+
+func (s *TinyLFUTestSuite) TestTinyLFUCache_SetGetDelete() {
+	s.cache.SetTTL(context.Background(), "key", "value", time.Minute)
+
+	value, err := s.cache.Get(context.Background(), "key")
+	s.NoError(err)
+	s.Equal("value", value)
+
+	err = s.cache.Delete(context.Background(), "key")
+	s.NoError(err)
+
+	_, err = s.cache.Get(context.Background(), "key")
+	s.True(errors.Is(err, CacheMissErr))
+}
+
+func (s *TinyLFUTestSuite) TestTinyLFUCache_BoundedByCapacity() {
+	var evicted int
+	s.cache = NewTinyLFUCache(100, WithEvictionCallback(func(key string, value interface{}, reason EvictionReason) {
+		evicted++
+	}))
+
+	for i := range 500 {
+		s.cache.SetTTL(context.Background(), strconv.Itoa(i), i, time.Minute)
+	}
+
+	s.Equal(uint64(100), s.cache.Size(context.Background()))
+	s.Equal(400, evicted)
+
+	p := s.cache.policy.(*tinyLFUPolicy)
+	s.Equal(100, p.window.Len()+p.probation.Len()+p.protected.Len())
+}
+
+func (s *TinyLFUTestSuite) TestTinyLFUCache_ScanResistant() {
+	lru := NewLRUCache(100)
+
+	for i := range 50 {
+		key := "hot:" + strconv.Itoa(i)
+		for _, c := range []Cache{s.cache, lru} {
+			c.SetTTL(context.Background(), key, i, time.Minute)
+			for range 5 {
+				c.Get(context.Background(), key)
+			}
+		}
+	}
+
+	// a batch job touches a lot of keys exactly once
+	for i := range 1000 {
+		key := "scan:" + strconv.Itoa(i)
+		s.cache.SetTTL(context.Background(), key, i, time.Minute)
+		lru.SetTTL(context.Background(), key, i, time.Minute)
+	}
+
+	var tinyLFUHits, lruHits int
+	for i := range 50 {
+		key := "hot:" + strconv.Itoa(i)
+		if _, err := s.cache.Get(context.Background(), key); err == nil {
+			tinyLFUHits++
+		}
+		if _, err := lru.Get(context.Background(), key); err == nil {
+			lruHits++
+		}
+	}
+
+	s.Equal(0, lruHits)
+	s.Equal(50, tinyLFUHits)
+}
+
+func (s *TinyLFUTestSuite) TestTinyLFUCache_Purge() {
+	for i := range 50 {
+		s.cache.SetTTL(context.Background(), strconv.Itoa(i), i, time.Minute)
+	}
+
+	s.cache.Purge(context.Background())
+	s.Equal(uint64(0), s.cache.Size(context.Background()))
+	s.Equal(uint8(0), s.cache.policy.(*tinyLFUPolicy).sketch.estimate("1"))
+}
+
+func (s *TinyLFUTestSuite) TestCountMinSketch() {
+	sketch := newCountMinSketch(100)
+
+	for range 5 {
+		sketch.increment("hot")
+	}
+	sketch.increment("cold")
+
+	s.GreaterOrEqual(sketch.estimate("hot"), uint8(5))
+	s.GreaterOrEqual(sketch.estimate("cold"), uint8(1))
+	s.Less(sketch.estimate("cold"), sketch.estimate("hot"))
+
+	// counters saturate
+	for range 100 {
+		sketch.increment("hot")
+	}
+	s.Equal(uint8(sketchMaxCounter), sketch.estimate("hot"))
+
+	// and fade over time
+	sketch.age()
+	s.Equal(uint8(sketchMaxCounter/2), sketch.estimate("hot"))
+}
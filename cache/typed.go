@@ -0,0 +1,150 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// TypedCache wraps a Cache so values go in and come out as V
+// instead of interface{}, regardless of the backend used
+type TypedCache[V any] struct {
+	cache Cache
+	codec Codec
+	loads flightGroup
+}
+
+// NewTypedCache wraps the cache. In-process caches store V as is, while
+// backends that can only store bytes (redis) default to the JSONCodec
+func NewTypedCache[V any](c Cache) *TypedCache[V] {
+	var codec Codec
+	if requiresEncoding(c) {
+		codec = JSONCodec{}
+	}
+	return NewTypedCacheWithCodec[V](c, codec)
+}
+
+// NewTypedCacheWithCodec wraps the cache and always encodes values using the codec.
+// A nil codec stores V as is, which only works with in-process caches
+func NewTypedCacheWithCodec[V any](c Cache, codec Codec) *TypedCache[V] {
+	return &TypedCache[V]{
+		cache: c,
+		codec: codec,
+	}
+}
+
+// Get retrieves the value given a key
+func (c *TypedCache[V]) Get(ctx context.Context, key string) (V, error) {
+	var value V
+
+	raw, err := c.cache.Get(ctx, key)
+	if err != nil {
+		return value, err
+	}
+
+	return c.decode(raw)
+}
+
+// Set adds the value for a given key, ttl is in seconds
+func (c *TypedCache[V]) Set(ctx context.Context, key string, value V, ttl int) error {
+	return c.SetTTL(ctx, key, value, seconds(ttl))
+}
+
+// SetTTL adds the value for a given key that lives for the ttl
+func (c *TypedCache[V]) SetTTL(ctx context.Context, key string, value V, ttl time.Duration) error {
+	raw, err := c.encode(value)
+	if err != nil {
+		return err
+	}
+	return c.cache.SetTTL(ctx, key, raw, ttl)
+}
+
+// GetOrLoad retrieves the value given a key, on a cache miss the loader is called and the result is cached.
+// Concurrent misses for the same key only call the loader once
+func (c *TypedCache[V]) GetOrLoad(ctx context.Context, key string, ttl time.Duration, loader func(ctx context.Context, key string) (V, error)) (V, error) {
+	value, err := c.Get(ctx, key)
+	if err == nil {
+		return value, nil
+	}
+
+	if !errors.Is(err, CacheMissErr) {
+		log.Err(err).Str("key", key).Msg("failed to get from cache, falling back to loader")
+	}
+
+	loaded, err := c.loads.do(ctx, key, func(ctx context.Context) (interface{}, error) {
+		value, err := loader(ctx, key)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := c.SetTTL(ctx, key, value, ttl); err != nil {
+			log.Err(err).Str("key", key).Msg("failed to cache loaded value")
+		}
+
+		return value, nil
+	})
+	if err != nil {
+		return value, err
+	}
+
+	value, _ = loaded.(V)
+	return value, nil
+}
+
+// Delete removes the item from the cache given the key
+func (c *TypedCache[V]) Delete(ctx context.Context, key string) error {
+	return c.cache.Delete(ctx, key)
+}
+
+// Purge clear all items in the cache
+func (c *TypedCache[V]) Purge(ctx context.Context) {
+	c.cache.Purge(ctx)
+}
+
+// Size returns the number of elements in the cache
+func (c *TypedCache[V]) Size(ctx context.Context) uint64 {
+	return c.cache.Size(ctx)
+}
+
+// Cache returns the underlying untyped cache
+func (c *TypedCache[V]) Cache() Cache {
+	return c.cache
+}
+
+func (c *TypedCache[V]) encode(value V) (interface{}, error) {
+	if c.codec == nil {
+		return value, nil
+	}
+	return c.codec.Encode(value)
+}
+
+func (c *TypedCache[V]) decode(raw interface{}) (V, error) {
+	var value V
+
+	if c.codec == nil {
+		value, ok := raw.(V)
+		if !ok {
+			return value, fmt.Errorf("%w: expected %T, got %T", TypeMismatchErr, value, raw)
+		}
+		return value, nil
+	}
+
+	var data []byte
+	switch v := raw.(type) {
+	case []byte:
+		data = v
+	case string:
+		// redis always hands back strings
+		data = []byte(v)
+	default:
+		return value, fmt.Errorf("%w: expected encoded value, got %T", TypeMismatchErr, raw)
+	}
+
+	if err := c.codec.Decode(data, &value); err != nil {
+		return value, err
+	}
+	return value, nil
+}
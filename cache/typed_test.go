@@ -0,0 +1,95 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type user struct {
+	ID   int
+	Name string
+}
+
+type TypedCacheTestSuite struct {
+	suite.Suite
+}
+
+func TestTypedCacheSuite(t *testing.T) {
+	suite.Run(t, new(TypedCacheTestSuite))
+}
+
+func (s *TypedCacheTestSuite) TestTyped_SetGet() {
+	backends := map[string]Cache{
+		"in memory": NewInMemoryCache(),
+		"lru":       NewLRUCache(10),
+	}
+
+	for name, backend := range backends {
+		s.Run(name, func() {
+			typed := NewTypedCache[user](backend)
+			expected := user{ID: 1, Name: "dave"}
+
+			err := typed.Set(context.Background(), "user", expected, 5)
+			s.NoError(err)
+
+			value, err := typed.Get(context.Background(), "user")
+			s.NoError(err)
+			s.Equal(expected, value)
+		})
+	}
+}
+
+func (s *TypedCacheTestSuite) TestTyped_Codecs() {
+	codecs := map[string]Codec{
+		"json": JSONCodec{},
+		"gob":  GobCodec{},
+	}
+
+	for name, codec := range codecs {
+		s.Run(name, func() {
+			backend := NewInMemoryCache()
+			typed := NewTypedCacheWithCodec[user](backend, codec)
+			expected := user{ID: 1, Name: "dave"}
+
+			err := typed.Set(context.Background(), "user", expected, 5)
+			s.NoError(err)
+
+			// the backend only ever sees the encoded bytes
+			raw, err := backend.Get(context.Background(), "user")
+			s.NoError(err)
+			s.IsType([]byte{}, raw)
+
+			// redis hands back strings so those need to decode too
+			backend.Set(context.Background(), "user", string(raw.([]byte)), 5)
+
+			value, err := typed.Get(context.Background(), "user")
+			s.NoError(err)
+			s.Equal(expected, value)
+		})
+	}
+}
+
+func (s *TypedCacheTestSuite) TestTyped_CacheMiss() {
+	typed := NewTypedCache[user](NewInMemoryCache())
+
+	value, err := typed.Get(context.Background(), "does_not_exist")
+	s.True(errors.Is(err, CacheMissErr))
+	s.Equal(user{}, value)
+}
+
+func (s *TypedCacheTestSuite) TestTyped_TypeMismatch() {
+	backend := NewInMemoryCache()
+	backend.Set(context.Background(), "user", "not a user", 5)
+
+	typed := NewTypedCache[user](backend)
+	_, err := typed.Get(context.Background(), "user")
+	s.True(errors.Is(err, TypeMismatchErr))
+}
+
+func (s *TypedCacheTestSuite) TestTyped_RequiresEncoding() {
+	s.Nil(NewTypedCache[user](NewLRUCache(10)).codec)
+	s.Equal(JSONCodec{}, NewTypedCache[user](&redisCache{}).codec)
+}
@@ -3,6 +3,14 @@ package derror
 type Code int
 
 const (
-	InternalServerCode Code = 500
-	BadRequestCode     Code = 400
+	InternalServerCode     Code = 500
+	BadGatewayCode         Code = 502
+	ServiceUnavailableCode Code = 503
+	GatewayTimeoutCode     Code = 504
+	BadRequestCode         Code = 400
+	UnauthorizedCode       Code = 401
+	ForbiddenCode          Code = 403
+	NotFoundCode           Code = 404
+	ConflictCode           Code = 409
+	TooManyRequestsCode    Code = 429
 )
@@ -3,6 +3,15 @@ package derror
 type Type string
 
 const (
-	InternalType   Type = "INTERNAL_ERROR"
-	BadRequestType Type = "BAD_REQUEST"
+	InternalType     Type = "INTERNAL_ERROR"
+	BadRequestType   Type = "BAD_REQUEST"
+	CircuitOpenType  Type = "CIRCUIT_OPEN"
+	UnauthorizedType Type = "UNAUTHORIZED"
+	ForbiddenType    Type = "FORBIDDEN"
+	NotFoundType     Type = "NOT_FOUND"
+	ConflictType     Type = "CONFLICT"
+	RateLimitedType  Type = "RATE_LIMITED"
+	UpstreamType     Type = "UPSTREAM_ERROR"
+	UnavailableType  Type = "UNAVAILABLE"
+	TimeoutType      Type = "TIMEOUT"
 )
@@ -0,0 +1,267 @@
+package httpclient
+
+import (
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+)
+
+var CircuitOpenErr = errors.New("circuit breaker is open")
+
+const (
+	DefaultConsecutiveFailures = 5
+	DefaultMinRequests         = 20
+	DefaultFailureWindow       = time.Minute
+	DefaultCoolDown            = 30 * time.Second
+	DefaultHalfOpenRequests    = 1
+)
+
+// CircuitState is the state of the circuit for one host
+type CircuitState int
+
+const (
+	// CircuitClosed lets every request through
+	CircuitClosed CircuitState = iota
+	// CircuitOpen fails every request right away until the cool-down is over
+	CircuitOpen
+	// CircuitHalfOpen lets a few probe requests through to see if the upstream recovered
+	CircuitHalfOpen
+)
+
+func (s CircuitState) String() string {
+	switch s {
+	case CircuitClosed:
+		return "closed"
+	case CircuitOpen:
+		return "open"
+	case CircuitHalfOpen:
+		return "half-open"
+	}
+	return "unknown"
+}
+
+// BreakerSettings configures the circuit breaker. Zero values use the defaults
+type BreakerSettings struct {
+	// ConsecutiveFailures opens the circuit after this many failures in a row
+	ConsecutiveFailures int
+
+	// FailureRate opens the circuit when at least this share (0-1) of the requests in the window failed, 0 disables it
+	FailureRate float64
+
+	// MinRequests is how many requests the window needs before FailureRate is checked
+	MinRequests int
+
+	// FailureWindow is how long requests are counted for FailureRate before the counts start over
+	FailureWindow time.Duration
+
+	// CoolDown is how long the circuit stays open before letting probes through
+	CoolDown time.Duration
+
+	// HalfOpenRequests is how many probes are let through at once, the circuit closes once that many succeed
+	HalfOpenRequests int
+
+	// OnStateChange is called whenever the circuit for a host changes state
+	OnStateChange func(host string, from, to CircuitState)
+}
+
+// WithCircuitBreaker fails requests to a host right away while it keeps failing, see BreakerSettings.
+// Network errors and 5xx count as failures. Open circuits return a derror with CircuitOpenType
+func WithCircuitBreaker(settings BreakerSettings) Option {
+	return func(hc *httpClient) {
+		hc.breaker = newCircuitBreaker(settings)
+	}
+}
+
+// circuitBreaker keeps a circuit per host
+type circuitBreaker struct {
+	settings BreakerSettings
+	now      func() time.Time
+
+	mu       sync.Mutex
+	circuits map[string]*circuit
+}
+
+type circuit struct {
+	state       CircuitState
+	consecutive int // failures in a row
+	requests    int // requests in the current window
+	failures    int // failures in the current window
+	windowStart time.Time
+	openedAt    time.Time
+	probes      int // probes in flight while half-open
+	successes   int // successful probes while half-open
+}
+
+type stateChange struct {
+	host     string
+	from, to CircuitState
+}
+
+func newCircuitBreaker(settings BreakerSettings) *circuitBreaker {
+	if settings.ConsecutiveFailures <= 0 {
+		settings.ConsecutiveFailures = DefaultConsecutiveFailures
+	}
+	if settings.MinRequests <= 0 {
+		settings.MinRequests = DefaultMinRequests
+	}
+	if settings.FailureWindow <= 0 {
+		settings.FailureWindow = DefaultFailureWindow
+	}
+	if settings.CoolDown <= 0 {
+		settings.CoolDown = DefaultCoolDown
+	}
+	if settings.HalfOpenRequests <= 0 {
+		settings.HalfOpenRequests = DefaultHalfOpenRequests
+	}
+
+	return &circuitBreaker{
+		settings: settings,
+		now:      time.Now,
+		circuits: make(map[string]*circuit),
+	}
+}
+
+// roundTripper guards the next RoundTripper with the circuit of the request's host
+func (b *circuitBreaker) roundTripper(next http.RoundTripper) http.RoundTripper {
+	return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		host := req.URL.Host
+		if err := b.allow(host); err != nil {
+			return nil, err
+		}
+
+		resp, err := next.RoundTrip(req)
+		switch {
+		case err != nil && req.Context().Err() != nil:
+			// the caller gave up, that says nothing about the upstream
+			b.release(host)
+		case err != nil, resp.StatusCode >= http.StatusInternalServerError:
+			b.record(host, false)
+		default:
+			b.record(host, true)
+		}
+		return resp, err
+	})
+}
+
+// state returns the current state of the host's circuit
+func (b *circuitBreaker) state(host string) CircuitState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if c, found := b.circuits[host]; found {
+		return c.state
+	}
+	return CircuitClosed
+}
+
+// allow returns CircuitOpenErr if the request can't go through
+func (b *circuitBreaker) allow(host string) error {
+	var changes []stateChange
+	defer func() { b.notify(changes) }()
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	c := b.circuit(host)
+	switch c.state {
+	case CircuitOpen:
+		if b.now().Sub(c.openedAt) < b.settings.CoolDown {
+			return CircuitOpenErr
+		}
+		changes = append(changes, b.transition(host, c, CircuitHalfOpen))
+		fallthrough
+	case CircuitHalfOpen:
+		if c.probes >= b.settings.HalfOpenRequests {
+			return CircuitOpenErr
+		}
+		c.probes++
+	}
+	return nil
+}
+
+// record counts the outcome of a request that was let through
+func (b *circuitBreaker) record(host string, success bool) {
+	var changes []stateChange
+	defer func() { b.notify(changes) }()
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	c := b.circuit(host)
+	switch c.state {
+	case CircuitHalfOpen:
+		c.probes = max(c.probes-1, 0)
+		if !success {
+			changes = append(changes, b.transition(host, c, CircuitOpen))
+			return
+		}
+		c.successes++
+		if c.successes >= b.settings.HalfOpenRequests {
+			changes = append(changes, b.transition(host, c, CircuitClosed))
+		}
+	case CircuitClosed:
+		if now := b.now(); now.Sub(c.windowStart) >= b.settings.FailureWindow {
+			c.windowStart, c.requests, c.failures = now, 0, 0
+		}
+		c.requests++
+		if success {
+			c.consecutive = 0
+			return
+		}
+		c.failures++
+		c.consecutive++
+		if c.consecutive >= b.settings.ConsecutiveFailures || b.failureRateExceeded(c) {
+			changes = append(changes, b.transition(host, c, CircuitOpen))
+		}
+	}
+	// outcomes of requests that were let through before the circuit opened are ignored
+}
+
+// release frees a probe slot without counting the request
+func (b *circuitBreaker) release(host string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if c := b.circuit(host); c.state == CircuitHalfOpen {
+		c.probes = max(c.probes-1, 0)
+	}
+}
+
+func (b *circuitBreaker) failureRateExceeded(c *circuit) bool {
+	return b.settings.FailureRate > 0 &&
+		c.requests >= b.settings.MinRequests &&
+		float64(c.failures)/float64(c.requests) >= b.settings.FailureRate
+}
+
+// circuit returns the host's circuit, creating it if needed. b.mu must be held
+func (b *circuitBreaker) circuit(host string) *circuit {
+	c, found := b.circuits[host]
+	if !found {
+		c = &circuit{windowStart: b.now()}
+		b.circuits[host] = c
+	}
+	return c
+}
+
+// transition moves the circuit to a new state and resets its counts. b.mu must be held
+func (b *circuitBreaker) transition(host string, c *circuit, to CircuitState) stateChange {
+	change := stateChange{host: host, from: c.state, to: to}
+
+	now := b.now()
+	*c = circuit{state: to, windowStart: now}
+	if to == CircuitOpen {
+		c.openedAt = now
+	}
+	return change
+}
+
+// notify calls OnStateChange outside of the lock so the callback can use the client
+func (b *circuitBreaker) notify(changes []stateChange) {
+	if b.settings.OnStateChange == nil {
+		return
+	}
+	for _, change := range changes {
+		b.settings.OnStateChange(change.host, change.from, change.to)
+	}
+}
@@ -0,0 +1,133 @@
+package httpclient
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/meowmix1337/go-core/derror"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	var status atomic.Int32
+	var hits atomic.Int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		w.WriteHeader(int(status.Load()))
+	}))
+	defer ts.Close()
+
+	var changes []string
+	now := time.Now()
+	client := httpClient{BaseURL: ts.URL}
+	WithCircuitBreaker(BreakerSettings{
+		ConsecutiveFailures: 3,
+		CoolDown:            time.Minute,
+		OnStateChange: func(host string, from, to CircuitState) {
+			changes = append(changes, from.String()+"->"+to.String())
+		},
+	})(&client)
+	client.breaker.now = func() time.Time { return now }
+
+	// 4xx is the caller's problem, not the upstream's
+	status.Store(http.StatusNotFound)
+	for range 5 {
+		client.Request(context.Background(), http.MethodGet, "/", nil, nil)
+	}
+	assert.Empty(t, changes)
+
+	status.Store(http.StatusInternalServerError)
+	for range 3 {
+		client.Request(context.Background(), http.MethodGet, "/", nil, nil)
+	}
+	assert.Equal(t, []string{"closed->open"}, changes)
+
+	// the upstream isn't called while the circuit is open
+	hits.Store(0)
+	_, err := client.Request(context.Background(), http.MethodGet, "/", nil, nil)
+	var derr *derror.Error
+	assert.True(t, errors.As(err, &derr))
+	assert.Equal(t, derror.CircuitOpenType, derr.Type)
+	assert.Equal(t, derror.ServiceUnavailableCode, derr.Code)
+	assert.True(t, derr.IsRetryable())
+	assert.ErrorIs(t, err, CircuitOpenErr)
+	assert.Equal(t, int32(0), hits.Load())
+
+	// a failed probe opens it again
+	now = now.Add(time.Minute)
+	client.Request(context.Background(), http.MethodGet, "/", nil, nil)
+	assert.Equal(t, []string{"closed->open", "open->half-open", "half-open->open"}, changes)
+	assert.Equal(t, int32(1), hits.Load())
+
+	// a successful probe closes it
+	now = now.Add(time.Minute)
+	status.Store(http.StatusOK)
+	_, err = client.Request(context.Background(), http.MethodGet, "/", nil, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"closed->open", "open->half-open", "half-open->open", "open->half-open", "half-open->closed"}, changes)
+	assert.Equal(t, CircuitClosed, client.breaker.state(strings.TrimPrefix(ts.URL, "http://")))
+}
+
+func TestCircuitBreakerFailureRate(t *testing.T) {
+	now := time.Now()
+	breaker := newCircuitBreaker(BreakerSettings{
+		ConsecutiveFailures: 100,
+		FailureRate:         0.5,
+		MinRequests:         4,
+		FailureWindow:       time.Minute,
+	})
+	breaker.now = func() time.Time { return now }
+
+	tests := []struct {
+		name     string
+		advance  time.Duration
+		outcomes []bool
+		expected CircuitState
+	}{
+		{name: "not enough requests", outcomes: []bool{false, true, false}, expected: CircuitClosed},
+		{name: "window starts over", advance: time.Minute, outcomes: []bool{true, true, true, false}, expected: CircuitClosed},
+		{name: "rate reached", outcomes: []bool{false, false}, expected: CircuitOpen},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			now = now.Add(tt.advance)
+			for _, success := range tt.outcomes {
+				assert.NoError(t, breaker.allow("a"))
+				breaker.record("a", success)
+			}
+			assert.Equal(t, tt.expected, breaker.state("a"))
+		})
+	}
+
+	// other hosts have their own circuit
+	assert.Equal(t, CircuitClosed, breaker.state("b"))
+	assert.NoError(t, breaker.allow("b"))
+}
+
+func TestCircuitBreakerHalfOpenProbes(t *testing.T) {
+	now := time.Now()
+	breaker := newCircuitBreaker(BreakerSettings{ConsecutiveFailures: 1, CoolDown: time.Second, HalfOpenRequests: 2})
+	breaker.now = func() time.Time { return now }
+
+	assert.NoError(t, breaker.allow("a"))
+	breaker.record("a", false)
+	assert.ErrorIs(t, breaker.allow("a"), CircuitOpenErr)
+
+	now = now.Add(time.Second)
+	assert.NoError(t, breaker.allow("a"))
+	assert.NoError(t, breaker.allow("a"))
+	// only two probes at once
+	assert.ErrorIs(t, breaker.allow("a"), CircuitOpenErr)
+
+	breaker.record("a", true)
+	assert.Equal(t, CircuitHalfOpen, breaker.state("a"))
+	breaker.record("a", true)
+	assert.Equal(t, CircuitClosed, breaker.state("a"))
+}
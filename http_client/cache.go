@@ -0,0 +1,237 @@
+package httpclient
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/meowmix1337/go-core/cache"
+	"github.com/meowmix1337/go-core/http_util"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// DefaultRevalidateTTL is how long a response with an ETag or Last-Modified is kept after it goes stale, so it can be revalidated
+	DefaultRevalidateTTL = time.Hour
+
+	// maxCachedBodySize is the largest body that is cached, bigger responses are passed through
+	maxCachedBodySize = 1 << 20 // 1MB
+)
+
+// cachedResponse is what gets stored. A response that varies on request headers is stored under a key including
+// those headers, the URL's own key only holds the names of the headers (Vary), same as http_util.CacheMiddleware
+type cachedResponse struct {
+	Vary       []string    `json:"vary,omitempty"`
+	Status     int         `json:"status"`
+	Header     http.Header `json:"header"`
+	Body       []byte      `json:"body"`
+	FreshUntil time.Time   `json:"fresh_until"`
+}
+
+// responseCache is a private HTTP cache (RFC 9111) for GET requests. Fresh responses are served without calling the upstream,
+// stale ones are revalidated with If-None-Match/If-Modified-Since and a 304 serves the cached body
+type responseCache struct {
+	responses *cache.TypedCache[cachedResponse]
+}
+
+func newResponseCache(c cache.Cache) *responseCache {
+	return &responseCache{
+		responses: cache.NewTypedCache[cachedResponse](c),
+	}
+}
+
+// roundTripper caches GET responses of the next RoundTripper. It runs after the middleware so it sees every header they add
+func (rc *responseCache) roundTripper(next http.RoundTripper) http.RoundTripper {
+	return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		if req.Method != http.MethodGet {
+			return next.RoundTrip(req)
+		}
+		return rc.do(req.Context(), req, next.RoundTrip)
+	})
+}
+
+// do serves the request from the cache when possible, otherwise it is sent and the response cached if it allows it
+func (rc *responseCache) do(ctx context.Context, req *http.Request, send func(*http.Request) (*http.Response, error)) (*http.Response, error) {
+	urlKey := cacheKey(req)
+	key, cached, found := rc.lookup(ctx, urlKey, req.Header)
+
+	if found && time.Now().Before(cached.FreshUntil) {
+		return cached.response(req), nil
+	}
+	if found {
+		// the validators are only added to our own copy of the request
+		req = req.Clone(ctx)
+		if etag := cached.Header.Get("ETag"); etag != "" {
+			req.Header.Set("If-None-Match", etag)
+		}
+		if lastModified := cached.Header.Get("Last-Modified"); lastModified != "" {
+			req.Header.Set("If-Modified-Since", lastModified)
+		}
+	}
+
+	resp, err := send(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if found && resp.StatusCode == http.StatusNotModified {
+		resp.Body.Close()
+
+		// a 304 carries the headers that changed, e.g. a new Cache-Control or ETag.
+		// In-process caches hand back the same map to everyone so it is copied first
+		header := cached.Header.Clone()
+		for name, values := range resp.Header {
+			header[name] = values
+		}
+		cached.Header = header
+		rc.store(ctx, key, cached)
+		return cached.response(req), nil
+	}
+
+	if resp.StatusCode == http.StatusOK || resp.StatusCode == http.StatusNonAuthoritativeInfo {
+		rc.storeResponse(ctx, urlKey, req.Header, resp)
+	}
+	return resp, nil
+}
+
+// cacheKey is the key of the request's URL. A request with Authorization gets its own keys per credential
+// since the response can be for that user only, the credential is hashed so it isn't kept in the cache
+func cacheKey(req *http.Request) string {
+	if auth := req.Header.Get("Authorization"); auth != "" {
+		sum := sha256.Sum256([]byte(auth))
+		return "http_client:" + hex.EncodeToString(sum[:]) + ":" + req.URL.String()
+	}
+	return "http_client:" + req.URL.String()
+}
+
+// lookup follows the URL's key to the variant matching the request's headers, returning the key the response is stored under
+func (rc *responseCache) lookup(ctx context.Context, key string, header http.Header) (string, cachedResponse, bool) {
+	cached, err := rc.responses.Get(ctx, key)
+	if err == nil && len(cached.Vary) > 0 {
+		key = http_util.VariantKey(key, cached.Vary, header)
+		cached, err = rc.responses.Get(ctx, key)
+	}
+
+	if err != nil {
+		if !errors.Is(err, cache.CacheMissErr) {
+			log.Err(err).Str("key", key).Msg("failed to get cached response")
+		}
+		return key, cachedResponse{}, false
+	}
+	return key, cached, true
+}
+
+// storeResponse reads the body so it can be cached and replaces it so the caller can still read it.
+// A response that varies on request headers is stored for the values this request had
+func (rc *responseCache) storeResponse(ctx context.Context, key string, reqHeader http.Header, resp *http.Response) {
+	if _, _, cacheable := freshness(resp.Header); !cacheable {
+		return
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxCachedBodySize+1))
+	if err != nil {
+		// the caller gets the same error when they read the body
+		resp.Body = readCloser{io.MultiReader(bytes.NewReader(body), errReader{err}), resp.Body}
+		return
+	}
+	if len(body) > maxCachedBodySize {
+		resp.Body = readCloser{io.MultiReader(bytes.NewReader(body), resp.Body), resp.Body}
+		return
+	}
+	resp.Body.Close()
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+
+	if vary := http_util.VaryHeaders(resp.Header); len(vary) > 0 {
+		// the names of the headers live as long as the variant so a lookup never finds one without the other
+		rc.store(ctx, key, cachedResponse{Vary: vary, Header: resp.Header})
+		key = http_util.VariantKey(key, vary, reqHeader)
+	}
+	rc.store(ctx, key, cachedResponse{
+		Status: resp.StatusCode,
+		Header: resp.Header.Clone(),
+		Body:   body,
+	})
+}
+
+// store caches the response for as long as it is fresh, or longer if it can be revalidated
+func (rc *responseCache) store(ctx context.Context, key string, response cachedResponse) {
+	fresh, revalidate, cacheable := freshness(response.Header)
+	if !cacheable {
+		return
+	}
+
+	response.FreshUntil = time.Now().Add(fresh)
+	ttl := fresh
+	if revalidate {
+		ttl += DefaultRevalidateTTL
+	}
+
+	if err := rc.responses.SetTTL(ctx, key, response, ttl); err != nil {
+		log.Err(err).Str("key", key).Msg("failed to cache response")
+	}
+}
+
+// freshness returns how long the response is fresh for, whether it can be revalidated once stale and whether it can be cached at all
+func freshness(header http.Header) (time.Duration, bool, bool) {
+	revalidate := header.Get("ETag") != "" || header.Get("Last-Modified") != ""
+
+	directives := http_util.CacheControl(header)
+	if _, found := directives["no-store"]; found || slices.Contains(http_util.VaryHeaders(header), "*") {
+		return 0, false, false
+	}
+
+	var fresh time.Duration
+	if _, found := directives["no-cache"]; found {
+		// has to be revalidated every time
+		fresh = 0
+	} else if maxAge, found := directives["max-age"]; found {
+		seconds, err := strconv.Atoi(maxAge)
+		if err == nil && seconds > 0 {
+			fresh = time.Duration(seconds) * time.Second
+		}
+	} else if expires, err := http.ParseTime(header.Get("Expires")); err == nil {
+		fresh = max(time.Until(expires), 0)
+	}
+
+	return fresh, revalidate, fresh > 0 || revalidate
+}
+
+// response builds a new response from the cached one, every caller gets their own body to read
+func (r cachedResponse) response(req *http.Request) *http.Response {
+	header := r.Header.Clone()
+	header.Set("X-Cache", "HIT")
+
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", r.Status, http.StatusText(r.Status)),
+		StatusCode:    r.Status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader(r.Body)),
+		ContentLength: int64(len(r.Body)),
+		Request:       req,
+	}
+}
+
+// readCloser reads from the reader but closes the original body
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+type errReader struct {
+	err error
+}
+
+func (r errReader) Read([]byte) (int, error) {
+	return 0, r.err
+}
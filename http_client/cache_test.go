@@ -0,0 +1,274 @@
+package httpclient
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/meowmix1337/go-core/cache"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWithCache(t *testing.T) {
+	tests := []struct {
+		name          string
+		header        http.Header
+		expectedCalls int32
+	}{
+		{
+			name:          "max-age is served from the cache",
+			header:        http.Header{"Cache-Control": {"max-age=60"}},
+			expectedCalls: 1,
+		},
+		{
+			name:          "no-store is not cached",
+			header:        http.Header{"Cache-Control": {"no-store, max-age=60"}},
+			expectedCalls: 2,
+		},
+		{
+			name:          "no cache headers is not cached",
+			header:        http.Header{},
+			expectedCalls: 2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var calls atomic.Int32
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				calls.Add(1)
+				for name, values := range tt.header {
+					w.Header()[name] = values
+				}
+				w.Write([]byte(`{"data": "test data"}`))
+			}))
+			defer ts.Close()
+
+			client := New(ts.URL, "/api", WithCache(cache.NewLRUCache(100)))
+			for range 2 {
+				resp, err := client.Get(context.Background(), "/data", map[string]string{"breed": "corgi"})
+				assert.NoError(t, err)
+				assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+				body, err := io.ReadAll(resp.Body)
+				assert.NoError(t, err)
+				assert.Equal(t, `{"data": "test data"}`, string(body))
+			}
+			assert.Equal(t, tt.expectedCalls, calls.Load())
+		})
+	}
+}
+
+func TestWithCache_Revalidate(t *testing.T) {
+	tests := []struct {
+		name        string
+		header      http.Header
+		validator   string
+		conditional string
+	}{
+		{
+			name:        "etag",
+			header:      http.Header{"Cache-Control": {"no-cache"}, "Etag": {`"v1"`}},
+			validator:   `"v1"`,
+			conditional: "If-None-Match",
+		},
+		{
+			name:        "last-modified",
+			header:      http.Header{"Cache-Control": {"max-age=0"}, "Last-Modified": {"Wed, 21 Oct 2015 07:28:00 GMT"}},
+			validator:   "Wed, 21 Oct 2015 07:28:00 GMT",
+			conditional: "If-Modified-Since",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var calls, notModified atomic.Int32
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				calls.Add(1)
+				for name, values := range tt.header {
+					w.Header()[name] = values
+				}
+				if r.Header.Get(tt.conditional) == tt.validator {
+					notModified.Add(1)
+					w.WriteHeader(http.StatusNotModified)
+					return
+				}
+				w.Write([]byte("hello"))
+			}))
+			defer ts.Close()
+
+			client := New(ts.URL, "", WithCache(cache.NewLRUCache(100)))
+			for range 3 {
+				resp, err := client.Get(context.Background(), "/data", nil)
+				assert.NoError(t, err)
+				assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+				body, err := io.ReadAll(resp.Body)
+				assert.NoError(t, err)
+				assert.Equal(t, "hello", string(body))
+			}
+			assert.Equal(t, int32(3), calls.Load())
+			assert.Equal(t, int32(2), notModified.Load())
+		})
+	}
+}
+
+func TestWithCache_NotModifiedRefreshesFreshness(t *testing.T) {
+	var calls atomic.Int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.Header().Set("ETag", `"v1"`)
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			// fresh for a minute from now on
+			w.Header().Set("Cache-Control", "max-age=60")
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Write([]byte("hello"))
+	}))
+	defer ts.Close()
+
+	client := New(ts.URL, "", WithCache(cache.NewLRUCache(100)))
+	for range 3 {
+		resp, err := client.Get(context.Background(), "/data", nil)
+		assert.NoError(t, err)
+		body, _ := io.ReadAll(resp.Body)
+		assert.Equal(t, "hello", string(body))
+	}
+
+	// the first call, the revalidation and then the cache
+	assert.Equal(t, int32(2), calls.Load())
+}
+
+func TestWithCache_PostNotCached(t *testing.T) {
+	var calls atomic.Int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.Header().Set("Cache-Control", "max-age=60")
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer ts.Close()
+
+	client := New(ts.URL, "", WithCache(cache.NewLRUCache(100)))
+	for range 2 {
+		_, err := client.Post(context.Background(), "/data", map[string]string{"foo": "bar"})
+		assert.NoError(t, err)
+	}
+	assert.Equal(t, int32(2), calls.Load())
+}
+
+func TestWithCache_Vary(t *testing.T) {
+	var calls atomic.Int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.Header().Set("Cache-Control", "max-age=60")
+		w.Header().Set("Vary", "Accept")
+		w.Write([]byte(r.Header.Get("Accept")))
+	}))
+	defer ts.Close()
+
+	store := cache.NewLRUCache(100)
+	for _, accept := range []string{"application/json", "text/plain", "application/json", "text/plain"} {
+		client := New(ts.URL, "", WithCache(store), WithMiddleware(DefaultHeaders(http.Header{"Accept": {accept}})))
+		resp, err := client.Get(context.Background(), "/data", nil)
+		assert.NoError(t, err)
+
+		body, err := io.ReadAll(resp.Body)
+		assert.NoError(t, err)
+		assert.Equal(t, accept, string(body))
+	}
+
+	// one call for each Accept, then the cache
+	assert.Equal(t, int32(2), calls.Load())
+}
+
+func TestWithCache_Authorization(t *testing.T) {
+	tests := []struct {
+		name  string
+		user  Middleware
+		other Middleware
+	}{
+		{
+			name:  "bearer token",
+			user:  BearerToken("secret"),
+			other: BearerToken("other"),
+		},
+		{
+			name:  "basic auth",
+			user:  BasicAuth("user", "password"),
+			other: BasicAuth("other", "password"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var calls atomic.Int32
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				calls.Add(1)
+				w.Header().Set("Cache-Control", "max-age=60")
+				w.Write([]byte(r.Header.Get("Authorization")))
+			}))
+			defer ts.Close()
+
+			// both clients share the cache but only get their own responses
+			responses := cache.NewLRUCache(100)
+			user := New(ts.URL, "", WithCache(responses), WithMiddleware(tt.user))
+			other := New(ts.URL, "", WithCache(responses), WithMiddleware(tt.other))
+
+			get := func(client *MyClient) (string, string) {
+				resp, err := client.Get(context.Background(), "/data", nil)
+				assert.NoError(t, err)
+				defer resp.Body.Close()
+				body, err := io.ReadAll(resp.Body)
+				assert.NoError(t, err)
+				return string(body), resp.Header.Get("X-Cache")
+			}
+
+			first, hit := get(user)
+			assert.Empty(t, hit)
+			second, hit := get(user)
+			assert.Equal(t, "HIT", hit)
+			assert.Equal(t, first, second)
+			assert.Equal(t, int32(1), calls.Load())
+
+			body, hit := get(other)
+			assert.Empty(t, hit)
+			assert.NotEqual(t, first, body)
+			assert.Equal(t, int32(2), calls.Load())
+
+			// the credential is hashed rather than kept in the keys
+			assert.Zero(t, responses.SizePrefix(context.Background(), "http_client:"+ts.URL))
+		})
+	}
+}
+
+func TestFreshness(t *testing.T) {
+	tests := []struct {
+		name               string
+		header             http.Header
+		expectedFresh      bool
+		expectedRevalidate bool
+		expectedCacheable  bool
+	}{
+		{name: "max-age", header: http.Header{"Cache-Control": {"public, max-age=60"}}, expectedFresh: true, expectedCacheable: true},
+		{name: "expires", header: http.Header{"Expires": {"Wed, 21 Oct 2099 07:28:00 GMT"}}, expectedFresh: true, expectedCacheable: true},
+		{name: "expired", header: http.Header{"Expires": {"Wed, 21 Oct 2015 07:28:00 GMT"}}},
+		{name: "no-cache with etag", header: http.Header{"Cache-Control": {"no-cache, max-age=60"}, "Etag": {`"v1"`}}, expectedRevalidate: true, expectedCacheable: true},
+		{name: "no-store", header: http.Header{"Cache-Control": {"no-store"}, "Etag": {`"v1"`}}},
+		{name: "vary *", header: http.Header{"Cache-Control": {"max-age=60"}, "Vary": {"*"}}},
+		{name: "nothing", header: http.Header{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fresh, revalidate, cacheable := freshness(tt.header)
+			assert.Equal(t, tt.expectedFresh, fresh > 0)
+			assert.Equal(t, tt.expectedRevalidate, revalidate)
+			assert.Equal(t, tt.expectedCacheable, cacheable)
+		})
+	}
+}
@@ -6,7 +6,10 @@ import (
 	"encoding/json"
 	"errors"
 	"io"
+	"maps"
 	"net/http"
+	"sync"
+	"time"
 
 	"github.com/meowmix1337/go-core/derror"
 )
@@ -20,17 +23,62 @@ type HttpClient interface {
 type httpClient struct {
 	BaseURL   string
 	APIPrefix string
+
+	cache   *responseCache  // GET responses are cached when set
+	retry   *RetryPolicy    // only one attempt is made when nil
+	breaker *circuitBreaker // every request goes through when nil
+
+	client        *http.Client  // http.DefaultClient when nil
+	ownsTransport bool          // the client's transport is a copy only this client uses
+	timeout       time.Duration // limits the whole call when set
+	maxBodySize   int64         // for the JSON helpers, DefaultMaxBodySize when 0
+	middleware    []Middleware  // wraps the client's transport
+
+	buildOnce sync.Once
+	sender    *http.Client // client with every layer around its transport, see build
 }
 
 // Request will make a request out the specified API endpoint
 func (c *httpClient) Request(ctx context.Context, method string, endpoint string, payload interface{}, queryParams map[string]string) (*http.Response, error) {
+	return c.requestWithHeader(ctx, method, endpoint, payload, queryParams, nil)
+}
+
+// requestWithHeader is Request with the header added to the request
+func (c *httpClient) requestWithHeader(ctx context.Context, method string, endpoint string, payload interface{}, queryParams map[string]string, header http.Header) (*http.Response, error) {
+	if c.timeout <= 0 {
+		return c.request(ctx, method, endpoint, payload, queryParams, header)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	resp, err := c.request(ctx, method, endpoint, payload, queryParams, header)
+	if resp == nil {
+		cancel()
+		return resp, err
+	}
+	// the body is read after returning, the timeout keeps applying until it is closed
+	resp.Body = &cancelBody{ReadCloser: resp.Body, cancel: cancel}
+	return resp, err
+}
+
+func (c *httpClient) request(ctx context.Context, method string, endpoint string, payload interface{}, queryParams map[string]string, header http.Header) (*http.Response, error) {
 
 	url := c.BaseURL + c.APIPrefix + endpoint
 
-	req, err := http.NewRequest(method, url, nil)
+	// the body is a bytes.Reader so it can be sent again on a retry
+	var body io.Reader
+	if payload != nil {
+		jsonBytes, err := json.Marshal(payload)
+		if err != nil {
+			return nil, derror.New(ctx, derror.InternalServerCode, derror.InternalType, "failed to marshal the payload", err)
+		}
+		body = bytes.NewReader(jsonBytes)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, url, body)
 	if err != nil {
 		return nil, derror.New(ctx, derror.InternalServerCode, derror.InternalType, "failed to create new request", err)
 	}
+	maps.Copy(req.Header, header)
 
 	if queryParams != nil {
 		q := req.URL.Query()
@@ -40,24 +88,61 @@ func (c *httpClient) Request(ctx context.Context, method string, endpoint string
 		req.URL.RawQuery = q.Encode()
 	}
 
-	if payload != nil {
-		jsonBytes, err := json.Marshal(payload)
-		if err != nil {
-			return nil, derror.New(ctx, derror.InternalServerCode, derror.InternalType, "failed to marshal the payload", err)
-		}
-		req.Body = io.NopCloser(bytes.NewBuffer(jsonBytes))
-		req.ContentLength = int64(len(jsonBytes))
+	resp, err := c.send(ctx, req)
+	if errors.Is(err, CircuitOpenErr) {
+		return nil, derror.NewRetryable(ctx, derror.ServiceUnavailableCode, derror.CircuitOpenType, "circuit breaker is open for "+req.URL.Host, err)
+	}
+	// middleware already returned a derror, e.g. BearerTokenFunc
+	var derr *derror.Error
+	if errors.As(err, &derr) {
+		return nil, derr
 	}
-
-	resp, err := http.DefaultClient.Do(req.WithContext(ctx))
 	if err != nil {
-		return nil, derror.New(ctx, derror.InternalServerCode, derror.InternalType, "failed to do request", err)
+		// the caller's context being done isn't something to retry, neither is a method the retry policy wouldn't retry
+		if ctx.Err() != nil || !c.retry.retryableMethod(req.Method) {
+			return nil, derror.New(ctx, derror.InternalServerCode, derror.InternalType, "failed to do request", err)
+		}
+		return nil, derror.NewRetryable(ctx, derror.InternalServerCode, derror.InternalType, "failed to do request", err)
 	}
 
 	// Check the response status code
 	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		return resp, derror.New(ctx, derror.InternalServerCode, derror.InternalType, "request response received a bad status code", errors.New("bad response code"))
+		return resp, upstreamError(ctx, req, resp)
 	}
 
 	return resp, nil
 }
+
+// do sends the request with the client set up by build
+func (c *httpClient) do(req *http.Request) (*http.Response, error) {
+	c.buildOnce.Do(c.build)
+	return c.sender.Do(req)
+}
+
+// build sets up the client requests are sent with, once every option is applied. Its transport is wrapped as
+// middleware -> cache -> circuit breaker -> transport, so the cache sees the headers the middleware add
+// and cache hits don't go through the circuit breaker
+func (c *httpClient) build() {
+	client := c.client
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	transport := client.Transport
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	if c.breaker != nil {
+		transport = c.breaker.roundTripper(transport)
+	}
+	if c.cache != nil {
+		transport = c.cache.roundTripper(transport)
+	}
+	for i := len(c.middleware) - 1; i >= 0; i-- {
+		transport = c.middleware[i](transport)
+	}
+
+	sender := *client
+	sender.Transport = transport
+	c.sender = &sender
+}
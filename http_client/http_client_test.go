@@ -183,6 +183,6 @@ func TestRequest_ErrorStatusCode(t *testing.T) {
 	}
 
 	assert.NotEmpty(t, err)
-	assert.Equal(t, "code=500, type=INTERNAL_ERROR, message=request response received a bad status code, err=bad response code", err.Error())
+	assert.Equal(t, "code=500, type=INTERNAL_ERROR, message=request response received a bad status code, err=GET "+ts.URL+" returned 500: {\"error\": \"server error\"}", err.Error())
 	assert.Equal(t, http.StatusInternalServerError, resp.StatusCode)
 }
@@ -0,0 +1,136 @@
+package httpclient
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/meowmix1337/go-core/derror"
+)
+
+// DefaultMaxBodySize is how much of a response body the JSON helpers read unless changed with WithMaxBodySize
+const DefaultMaxBodySize = 10 << 20 // 10MB
+
+var BodyTooLargeErr = errors.New("response body is too large")
+
+// ErrorResponse is the body of a non-2xx response. Code and Message are filled if the upstream sends them,
+// Body has the whole body for APIs with another shape
+type ErrorResponse struct {
+	StatusCode int    `json:"-"`
+	Code       string `json:"code"`
+	Message    string `json:"message"`
+	Body       []byte `json:"-"`
+
+	upstream error // the *UpstreamError from the request
+}
+
+func (e *ErrorResponse) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("status %d", e.StatusCode)
+	}
+	return fmt.Sprintf("status %d: %s", e.StatusCode, e.Message)
+}
+
+func (e *ErrorResponse) Unwrap() error {
+	return e.upstream
+}
+
+// WithMaxBodySize limits how much of a response body the JSON helpers read
+func WithMaxBodySize(size int64) Option {
+	return func(hc *httpClient) {
+		hc.maxBodySize = size
+	}
+}
+
+// GetJSON does a GET and decodes the response into T
+func GetJSON[T any](ctx context.Context, c *MyClient, endpoint string, queryParams map[string]string) (T, error) {
+	resp, err := requestJSON(ctx, c, http.MethodGet, endpoint, nil, queryParams)
+	return decodeJSON[T](ctx, c, resp, err)
+}
+
+// PostJSON does a POST with the payload as JSON and decodes the response into Resp
+func PostJSON[Req, Resp any](ctx context.Context, c *MyClient, endpoint string, payload Req) (Resp, error) {
+	resp, err := requestJSON(ctx, c, http.MethodPost, endpoint, payload, nil)
+	return decodeJSON[Resp](ctx, c, resp, err)
+}
+
+// PutJSON does a PUT with the payload as JSON and decodes the response into Resp
+func PutJSON[Req, Resp any](ctx context.Context, c *MyClient, endpoint string, payload Req) (Resp, error) {
+	resp, err := requestJSON(ctx, c, http.MethodPut, endpoint, payload, nil)
+	return decodeJSON[Resp](ctx, c, resp, err)
+}
+
+// DeleteJSON does a DELETE and decodes the response into T
+func DeleteJSON[T any](ctx context.Context, c *MyClient, endpoint string, payload interface{}) (T, error) {
+	resp, err := requestJSON(ctx, c, http.MethodDelete, endpoint, payload, nil)
+	return decodeJSON[T](ctx, c, resp, err)
+}
+
+// requestJSON sends the request with "Accept: application/json", and "Content-Type: application/json" when there is a payload.
+// A HttpClient other than the one from New sends it as is
+func requestJSON(ctx context.Context, c *MyClient, method string, endpoint string, payload interface{}, queryParams map[string]string) (*http.Response, error) {
+	hc, ok := c.client.(*httpClient)
+	if !ok {
+		return c.client.Request(ctx, method, endpoint, payload, queryParams)
+	}
+
+	header := http.Header{"Accept": {"application/json"}}
+	if payload != nil {
+		header.Set("Content-Type", "application/json")
+	}
+	return hc.requestWithHeader(ctx, method, endpoint, payload, queryParams, header)
+}
+
+// decodeJSON reads and closes the body. Error responses come back as the request's error wrapping an *ErrorResponse
+func decodeJSON[T any](ctx context.Context, c *MyClient, resp *http.Response, err error) (T, error) {
+	var result T
+	if resp == nil {
+		return result, err
+	}
+	defer resp.Body.Close()
+
+	body, readErr := readBody(resp.Body, c.maxBodySize)
+	if err != nil {
+		errResp := &ErrorResponse{StatusCode: resp.StatusCode, Body: body}
+		// not every upstream sends JSON errors, Body still has what it sent
+		json.Unmarshal(body, errResp)
+
+		var derr *derror.Error
+		if errors.As(err, &derr) {
+			errResp.upstream = derr.Err
+			return result, derr.Wrap(errResp)
+		}
+		return result, err
+	}
+	if readErr != nil {
+		return result, derror.New(ctx, derror.InternalServerCode, derror.InternalType, "failed to read the response", readErr)
+	}
+
+	// e.g. a 204
+	if len(body) == 0 {
+		return result, nil
+	}
+	if err := json.Unmarshal(body, &result); err != nil {
+		return result, derror.New(ctx, derror.InternalServerCode, derror.InternalType, "failed to decode the response", err)
+	}
+	return result, nil
+}
+
+// readBody reads up to maxSize bytes, BodyTooLargeErr if there is more
+func readBody(r io.Reader, maxSize int64) ([]byte, error) {
+	if maxSize <= 0 {
+		maxSize = DefaultMaxBodySize
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r, maxSize+1))
+	if err != nil {
+		return body, err
+	}
+	if int64(len(body)) > maxSize {
+		return body[:maxSize], BodyTooLargeErr
+	}
+	return body, nil
+}
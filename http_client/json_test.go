@@ -0,0 +1,195 @@
+package httpclient
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/meowmix1337/go-core/derror"
+	"github.com/stretchr/testify/assert"
+)
+
+type breed struct {
+	Name  string `json:"name"`
+	Count int    `json:"count"`
+}
+
+type closeTracker struct {
+	io.ReadCloser
+	closed bool
+}
+
+func (c *closeTracker) Close() error {
+	c.closed = true
+	return c.ReadCloser.Close()
+}
+
+func TestJSONHelpers(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/breeds/corgi":
+			w.Write([]byte(`{"name":"corgi","count":3}`))
+		case "/breeds":
+			body, _ := io.ReadAll(r.Body)
+			w.WriteHeader(http.StatusCreated)
+			w.Write(body)
+		case "/empty":
+			w.WriteHeader(http.StatusNoContent)
+		case "/large":
+			w.Write([]byte(`{"name":"` + strings.Repeat("a", 100) + `"}`))
+		case "/invalid":
+			w.Write([]byte(`{"name":`))
+		case "/missing":
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"code":"not_found","message":"no such breed"}`))
+		case "/text-error":
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`bad request`))
+		}
+	}))
+	defer ts.Close()
+
+	client := New(ts.URL, "", WithMaxBodySize(64))
+
+	tests := []struct {
+		name          string
+		call          func() (breed, error)
+		expected      breed
+		expectedErr   error
+		expectedError *ErrorResponse
+	}{
+		{
+			name:     "GetJSON decodes the body",
+			call:     func() (breed, error) { return GetJSON[breed](context.Background(), client, "/breeds/corgi", nil) },
+			expected: breed{Name: "corgi", Count: 3},
+		},
+		{
+			name: "PostJSON sends and decodes",
+			call: func() (breed, error) {
+				return PostJSON[breed, breed](context.Background(), client, "/breeds", breed{Name: "pug", Count: 1})
+			},
+			expected: breed{Name: "pug", Count: 1},
+		},
+		{
+			name: "empty body is the zero value",
+			call: func() (breed, error) { return GetJSON[breed](context.Background(), client, "/empty", nil) },
+		},
+		{
+			name:        "body over the max size",
+			call:        func() (breed, error) { return GetJSON[breed](context.Background(), client, "/large", nil) },
+			expectedErr: BodyTooLargeErr,
+		},
+		{
+			name:          "JSON error body",
+			call:          func() (breed, error) { return GetJSON[breed](context.Background(), client, "/missing", nil) },
+			expectedError: &ErrorResponse{StatusCode: http.StatusNotFound, Code: "not_found", Message: "no such breed", Body: []byte(`{"code":"not_found","message":"no such breed"}`)},
+		},
+		{
+			name:          "non JSON error body",
+			call:          func() (breed, error) { return GetJSON[breed](context.Background(), client, "/text-error", nil) },
+			expectedError: &ErrorResponse{StatusCode: http.StatusBadRequest, Body: []byte(`bad request`)},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			actual, err := tt.call()
+			assert.Equal(t, tt.expected, actual)
+
+			switch {
+			case tt.expectedErr != nil:
+				assert.ErrorIs(t, err, tt.expectedErr)
+			case tt.expectedError != nil:
+				var errResp *ErrorResponse
+				assert.True(t, errors.As(err, &errResp))
+				assert.Equal(t, tt.expectedError.StatusCode, errResp.StatusCode)
+				assert.Equal(t, tt.expectedError.Code, errResp.Code)
+				assert.Equal(t, tt.expectedError.Message, errResp.Message)
+				assert.Equal(t, tt.expectedError.Body, errResp.Body)
+
+				var upstream *UpstreamError
+				assert.True(t, errors.As(err, &upstream))
+				assert.Equal(t, tt.expectedError.Body, upstream.Body)
+
+				// still the derror from the request
+				var derr *derror.Error
+				assert.True(t, errors.As(err, &derr))
+			default:
+				assert.NoError(t, err)
+			}
+		})
+	}
+
+	_, err := GetJSON[breed](context.Background(), client, "/invalid", nil)
+	assert.Error(t, err)
+}
+
+func TestJSONHelpersHeaders(t *testing.T) {
+	var header http.Header
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header = r.Header
+		w.Write([]byte(`{}`))
+	}))
+	defer ts.Close()
+
+	client := New(ts.URL, "")
+
+	tests := []struct {
+		name                string
+		call                func() (breed, error)
+		expectedContentType string
+	}{
+		{
+			name:                "GetJSON has no payload",
+			call:                func() (breed, error) { return GetJSON[breed](context.Background(), client, "/breeds", nil) },
+			expectedContentType: "",
+		},
+		{
+			name: "PostJSON",
+			call: func() (breed, error) {
+				return PostJSON[breed, breed](context.Background(), client, "/breeds", breed{Name: "pug"})
+			},
+			expectedContentType: "application/json",
+		},
+		{
+			name: "PutJSON",
+			call: func() (breed, error) {
+				return PutJSON[breed, breed](context.Background(), client, "/breeds", breed{Name: "pug"})
+			},
+			expectedContentType: "application/json",
+		},
+		{
+			name:                "DeleteJSON without a payload",
+			call:                func() (breed, error) { return DeleteJSON[breed](context.Background(), client, "/breeds", nil) },
+			expectedContentType: "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := tt.call()
+			assert.NoError(t, err)
+			assert.Equal(t, "application/json", header.Get("Accept"))
+			assert.Equal(t, tt.expectedContentType, header.Get("Content-Type"))
+		})
+	}
+}
+
+func TestDecodeJSONClosesBody(t *testing.T) {
+	client := &MyClient{}
+	for _, status := range []int{http.StatusOK, http.StatusInternalServerError} {
+		body := &closeTracker{ReadCloser: io.NopCloser(strings.NewReader(`{}`))}
+		resp := &http.Response{StatusCode: status, Body: body}
+
+		var err error
+		if status != http.StatusOK {
+			err = derror.New(context.Background(), derror.InternalServerCode, derror.InternalType, "bad", errors.New("bad"))
+		}
+		decodeJSON[breed](context.Background(), client, resp, err)
+		assert.True(t, body.closed)
+	}
+}
@@ -0,0 +1,177 @@
+package httpclient
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/meowmix1337/go-core/derror"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+)
+
+// DefaultRequestIDHeader is the header RequestID sets when none is given
+const DefaultRequestIDHeader = "X-Request-Id"
+
+// DefaultRedacted are the headers and query params Logging never logs the value of
+var DefaultRedacted = []string{"Authorization", "Proxy-Authorization", "Cookie", "Set-Cookie", "X-Api-Key", "access_token", "api_key", "token"}
+
+// Middleware wraps the transport every attempt is sent through, e.g. to add headers or log.
+// It should not change the request it is given, clone it first
+type Middleware func(http.RoundTripper) http.RoundTripper
+
+// RoundTripperFunc lets a function be used as an http.RoundTripper
+type RoundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f RoundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// WithMiddleware adds middleware around the transport, the first one runs first.
+// Every attempt of a retry goes through it and so do cache hits, the cache sits below it to see the headers it adds
+func WithMiddleware(middleware ...Middleware) Option {
+	return func(hc *httpClient) {
+		hc.middleware = append(hc.middleware, middleware...)
+	}
+}
+
+// DefaultHeaders sets the headers on every request that doesn't already have them
+func DefaultHeaders(header http.Header) Middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			req = req.Clone(req.Context())
+			for name, values := range header {
+				if req.Header.Get(name) == "" {
+					req.Header[http.CanonicalHeaderKey(name)] = values
+				}
+			}
+			return next.RoundTrip(req)
+		})
+	}
+}
+
+// BearerToken sets "Authorization: Bearer <token>"
+func BearerToken(token string) Middleware {
+	return BearerTokenFunc(func(context.Context) (string, error) {
+		return token, nil
+	})
+}
+
+// BearerTokenFunc gets the token for every request, e.g. from a token source that refreshes it.
+// An error from token fails the request with a derror that isn't retryable, the request was never sent
+func BearerTokenFunc(token func(ctx context.Context) (string, error)) Middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			t, err := token(req.Context())
+			if err != nil {
+				return nil, derror.New(req.Context(), derror.InternalServerCode, derror.InternalType, "failed to get the bearer token", err)
+			}
+
+			req = req.Clone(req.Context())
+			req.Header.Set("Authorization", "Bearer "+t)
+			return next.RoundTrip(req)
+		})
+	}
+}
+
+// BasicAuth sets basic auth on every request
+func BasicAuth(username, password string) Middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			req = req.Clone(req.Context())
+			req.SetBasicAuth(username, password)
+			return next.RoundTrip(req)
+		})
+	}
+}
+
+type requestIDKey struct{}
+
+// ContextWithRequestID returns a context carrying the request ID for RequestID to send
+func ContextWithRequestID(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, requestID)
+}
+
+// RequestIDFromContext returns the request ID set with ContextWithRequestID
+func RequestIDFromContext(ctx context.Context) (string, bool) {
+	requestID, ok := ctx.Value(requestIDKey{}).(string)
+	return requestID, ok && requestID != ""
+}
+
+// RequestID sends the context's request ID in the header, DefaultRequestIDHeader if it is empty
+func RequestID(header string) Middleware {
+	if header == "" {
+		header = DefaultRequestIDHeader
+	}
+
+	return func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			requestID, found := RequestIDFromContext(req.Context())
+			if !found {
+				return next.RoundTrip(req)
+			}
+
+			req = req.Clone(req.Context())
+			req.Header.Set(header, requestID)
+			return next.RoundTrip(req)
+		})
+	}
+}
+
+// Logging logs every request and response at debug level, failures at warn.
+// Values of DefaultRedacted and redact headers and query params are replaced, names are matched case insensitively
+func Logging(redact ...string) Middleware {
+	redacted := make(map[string]bool)
+	for _, name := range append(DefaultRedacted, redact...) {
+		redacted[strings.ToLower(name)] = true
+	}
+
+	return func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			start := time.Now()
+			resp, err := next.RoundTrip(req)
+
+			var event *zerolog.Event
+			switch {
+			case err != nil:
+				event = log.Warn().Err(err)
+			case resp.StatusCode >= http.StatusInternalServerError:
+				event = log.Warn().Int("status", resp.StatusCode).Dict("response_headers", redactHeaders(resp.Header, redacted))
+			default:
+				event = log.Debug().Int("status", resp.StatusCode).Dict("response_headers", redactHeaders(resp.Header, redacted))
+			}
+			event.Str("method", req.Method).
+				Str("url", redactURL(req, redacted)).
+				Dict("request_headers", redactHeaders(req.Header, redacted)).
+				Dur("duration", time.Since(start)).
+				Msg("outbound request")
+
+			return resp, err
+		})
+	}
+}
+
+func redactHeaders(header http.Header, redacted map[string]bool) *zerolog.Event {
+	dict := zerolog.Dict()
+	for name, values := range header {
+		if redacted[strings.ToLower(name)] {
+			dict.Str(name, "REDACTED")
+			continue
+		}
+		dict.Strs(name, values)
+	}
+	return dict
+}
+
+func redactURL(req *http.Request, redacted map[string]bool) string {
+	u := *req.URL
+	query := u.Query()
+	for name := range query {
+		if redacted[strings.ToLower(name)] {
+			query.Set(name, "REDACTED")
+		}
+	}
+	u.RawQuery = query.Encode()
+	return u.Redacted()
+}
@@ -0,0 +1,133 @@
+package httpclient
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMiddleware(t *testing.T) {
+	var received http.Header
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = r.Header.Clone()
+	}))
+	defer ts.Close()
+
+	tests := []struct {
+		name     string
+		ctx      context.Context
+		opts     []Option
+		expected map[string]string
+	}{
+		{
+			name:     "default headers",
+			ctx:      context.Background(),
+			opts:     []Option{WithMiddleware(DefaultHeaders(http.Header{"User-Agent": {"go-core"}, "accept": {"application/json"}}))},
+			expected: map[string]string{"User-Agent": "go-core", "Accept": "application/json"},
+		},
+		{
+			name:     "bearer token",
+			ctx:      context.Background(),
+			opts:     []Option{WithMiddleware(BearerToken("abc"))},
+			expected: map[string]string{"Authorization": "Bearer abc"},
+		},
+		{
+			name:     "basic auth",
+			ctx:      context.Background(),
+			opts:     []Option{WithMiddleware(BasicAuth("user", "pass"))},
+			expected: map[string]string{"Authorization": "Basic dXNlcjpwYXNz"},
+		},
+		{
+			name:     "request ID from the context",
+			ctx:      ContextWithRequestID(context.Background(), "req-1"),
+			opts:     []Option{WithMiddleware(RequestID(""))},
+			expected: map[string]string{DefaultRequestIDHeader: "req-1"},
+		},
+		{
+			name:     "no request ID in the context",
+			ctx:      context.Background(),
+			opts:     []Option{WithMiddleware(RequestID("X-Correlation-Id"))},
+			expected: map[string]string{"X-Correlation-Id": ""},
+		},
+		{
+			name:     "first middleware runs first",
+			ctx:      context.Background(),
+			opts:     []Option{WithMiddleware(BearerToken("outer"), DefaultHeaders(http.Header{"Authorization": {"Bearer inner"}}))},
+			expected: map[string]string{"Authorization": "Bearer outer"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := New(ts.URL, "", tt.opts...)
+			_, err := client.Get(tt.ctx, "/", nil)
+			assert.NoError(t, err)
+			for name, value := range tt.expected {
+				assert.Equal(t, value, received.Get(name))
+			}
+		})
+	}
+}
+
+func TestMiddlewareRunsOnEveryAttempt(t *testing.T) {
+	attempts := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		assert.Equal(t, "Bearer abc", r.Header.Get("Authorization"))
+		if attempts == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer ts.Close()
+
+	client := New(ts.URL, "", WithRetry(RetryPolicy{BaseDelay: 1}), WithMiddleware(BearerToken("abc")))
+	_, err := client.Get(context.Background(), "/", nil)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, attempts)
+}
+
+func TestBearerTokenFuncError(t *testing.T) {
+	tokenErr := errors.New("token expired")
+	calls := 0
+	client := New("http://upstream.invalid", "", WithRetry(RetryPolicy{BaseDelay: 1}), WithMiddleware(BearerTokenFunc(func(context.Context) (string, error) {
+		calls++
+		return "", tokenErr
+	})))
+
+	_, err := client.Get(context.Background(), "/", nil)
+	assert.ErrorIs(t, err, tokenErr)
+	assert.False(t, isRetryable(err))
+	// not retried by the policy either
+	assert.Equal(t, 1, calls)
+}
+
+func TestLogging(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Set-Cookie", "session=secret")
+	}))
+	defer ts.Close()
+
+	var buf bytes.Buffer
+	logger := log.Logger
+	log.Logger = zerolog.New(&buf).Level(zerolog.DebugLevel)
+	defer func() { log.Logger = logger }()
+
+	client := New(ts.URL, "", WithMiddleware(BearerToken("secret"), Logging("X-Internal")))
+	_, err := client.Get(context.Background(), "/breeds", map[string]string{"token": "secret", "breed": "corgi"})
+	assert.NoError(t, err)
+
+	logged := buf.String()
+	assert.Contains(t, logged, `"message":"outbound request"`)
+	assert.Contains(t, logged, `"status":200`)
+	assert.Contains(t, logged, "breed=corgi")
+	assert.Contains(t, logged, `"Authorization":"REDACTED"`)
+	assert.Contains(t, logged, `"Set-Cookie":"REDACTED"`)
+	assert.NotContains(t, logged, "secret")
+}
@@ -7,15 +7,25 @@ import (
 
 // MyClient is a wrapper struct that implements specific HTTP methods
 type MyClient struct {
-	client HttpClient
+	client      HttpClient
+	maxBodySize int64 // how much of a body the JSON helpers read
 }
 
-func New(baseUrl, apiPrefix string) *MyClient {
+func New(baseUrl, apiPrefix string, opts ...Option) *MyClient {
+	client := &httpClient{
+		BaseURL:   baseUrl,
+		APIPrefix: apiPrefix,
+		// its own pool instead of the shared http.DefaultClient
+		client:        newHTTPClient(),
+		ownsTransport: true,
+	}
+	for _, opt := range opts {
+		opt(client)
+	}
+
 	return &MyClient{
-		client: &httpClient{
-			BaseURL:   baseUrl,
-			APIPrefix: apiPrefix,
-		},
+		client:      client,
+		maxBodySize: client.maxBodySize,
 	}
 }
 
@@ -0,0 +1,13 @@
+package httpclient
+
+import "github.com/meowmix1337/go-core/cache"
+
+// Option configures the client created by New
+type Option func(*httpClient)
+
+// WithCache caches GET responses in the cache following the upstream's Cache-Control, see responseCache
+func WithCache(c cache.Cache) Option {
+	return func(hc *httpClient) {
+		hc.cache = newResponseCache(c)
+	}
+}
@@ -0,0 +1,162 @@
+package httpclient
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/meowmix1337/go-core/derror"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	DefaultMaxAttempts = 3
+	DefaultBaseDelay   = 100 * time.Millisecond
+	DefaultMaxDelay    = 5 * time.Second
+
+	// maxDrain is how much of a body is read before a retry to reuse the connection, a bigger body closes it instead
+	maxDrain = 4 << 10
+)
+
+// RetryPolicy decides how failed requests are retried. Zero values use the defaults
+type RetryPolicy struct {
+	// MaxAttempts is the total number of attempts including the first one
+	MaxAttempts int
+
+	// BaseDelay is the backoff before the first retry, it doubles for every retry after that
+	BaseDelay time.Duration
+
+	// MaxDelay caps the backoff. A Retry-After longer than this isn't waited for, the error is returned instead
+	MaxDelay time.Duration
+
+	// RetryNonIdempotent retries POST and PATCH as well. By default they are only retried on a 429,
+	// since any other failure could have happened after the upstream already acted on the request
+	RetryNonIdempotent bool
+}
+
+// WithRetry retries network errors, 429s and 5xx with exponential backoff and jitter, honouring Retry-After
+func WithRetry(policy RetryPolicy) Option {
+	return func(hc *httpClient) {
+		if policy.MaxAttempts <= 0 {
+			policy.MaxAttempts = DefaultMaxAttempts
+		}
+		if policy.BaseDelay <= 0 {
+			policy.BaseDelay = DefaultBaseDelay
+		}
+		if policy.MaxDelay <= 0 {
+			policy.MaxDelay = DefaultMaxDelay
+		}
+		hc.retry = &policy
+	}
+}
+
+// send does the request, retrying it according to the policy if there is one
+func (c *httpClient) send(ctx context.Context, req *http.Request) (*http.Response, error) {
+	for attempt := 1; ; attempt++ {
+		resp, err := c.do(req)
+		if c.retry == nil || attempt >= c.retry.MaxAttempts || !c.retry.shouldRetry(req.Method, resp, err) {
+			return resp, err
+		}
+
+		delay, ok := c.retry.backoff(attempt, resp)
+		if !ok {
+			return resp, err
+		}
+
+		if resp != nil {
+			// the connection can only be reused once the body was read
+			io.CopyN(io.Discard, resp.Body, maxDrain)
+			resp.Body.Close()
+		}
+		log.Debug().Err(err).Str("url", req.URL.String()).Int("attempt", attempt).Dur("delay", delay).Msg("retrying request")
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+
+		if req.GetBody != nil {
+			if req.Body, err = req.GetBody(); err != nil {
+				return nil, err
+			}
+		}
+	}
+}
+
+// shouldRetry is true for network errors, 429s and 5xx. Non idempotent methods are only retried on a 429 unless the policy allows it
+func (p *RetryPolicy) shouldRetry(method string, resp *http.Response, err error) bool {
+	if err != nil {
+		// the caller gave up, retrying won't help
+		if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+			return false
+		}
+		// fail fast, that is the point of the breaker
+		if errors.Is(err, CircuitOpenErr) {
+			return false
+		}
+		// e.g. middleware that failed before sending the request
+		var derr *derror.Error
+		if errors.As(err, &derr) && !derr.IsRetryable() {
+			return false
+		}
+		return p.retryableMethod(method)
+	}
+
+	if resp.StatusCode == http.StatusTooManyRequests {
+		return true
+	}
+	return retryableStatus(resp.StatusCode) && p.retryableMethod(method)
+}
+
+// retryableMethod is true if a failure of the method can be retried, without a policy only idempotent methods are
+func (p *RetryPolicy) retryableMethod(method string) bool {
+	return (p != nil && p.RetryNonIdempotent) || idempotent(method)
+}
+
+// backoff returns how long to wait before the next attempt. Retry-After wins over the backoff,
+// false if the upstream asked to wait longer than MaxDelay
+func (p *RetryPolicy) backoff(attempt int, resp *http.Response) (time.Duration, bool) {
+	if resp != nil {
+		if retryAfter, found := parseRetryAfter(resp.Header.Get("Retry-After")); found {
+			return retryAfter, retryAfter <= p.MaxDelay
+		}
+	}
+
+	delay := min(p.BaseDelay<<(attempt-1), p.MaxDelay)
+	// jitter so clients that failed together don't retry together
+	return delay/2 + rand.N(delay/2+1), true
+}
+
+// parseRetryAfter reads Retry-After as either seconds or an HTTP date
+func parseRetryAfter(value string) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if at, err := http.ParseTime(value); err == nil {
+		return max(time.Until(at), 0), true
+	}
+	return 0, false
+}
+
+// retryableStatus is true for responses where trying again later can succeed
+func retryableStatus(status int) bool {
+	return status == http.StatusTooManyRequests || status >= http.StatusInternalServerError
+}
+
+func idempotent(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return false
+}
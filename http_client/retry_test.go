@@ -0,0 +1,197 @@
+package httpclient
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/meowmix1337/go-core/derror"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRetry(t *testing.T) {
+	tests := []struct {
+		name              string
+		method            string
+		payload           interface{}
+		policy            RetryPolicy
+		statuses          []int
+		retryAfter        string
+		expectedStatus    int
+		expectedAttempts  int32
+		expectedRetryable bool
+	}{
+		{
+			name:             "GET retries a 503 until it succeeds",
+			method:           http.MethodGet,
+			statuses:         []int{http.StatusServiceUnavailable, http.StatusServiceUnavailable, http.StatusOK},
+			expectedStatus:   http.StatusOK,
+			expectedAttempts: 3,
+		},
+		{
+			name:              "gives up after max attempts",
+			method:            http.MethodGet,
+			policy:            RetryPolicy{MaxAttempts: 2},
+			statuses:          []int{http.StatusBadGateway, http.StatusBadGateway, http.StatusOK},
+			expectedStatus:    http.StatusBadGateway,
+			expectedAttempts:  2,
+			expectedRetryable: true,
+		},
+		{
+			name:             "4xx isn't retried",
+			method:           http.MethodGet,
+			statuses:         []int{http.StatusNotFound, http.StatusOK},
+			expectedStatus:   http.StatusNotFound,
+			expectedAttempts: 1,
+		},
+		{
+			name:              "POST isn't retried on a 5xx",
+			method:            http.MethodPost,
+			payload:           map[string]string{"name": "corgi"},
+			statuses:          []int{http.StatusInternalServerError, http.StatusOK},
+			expectedStatus:    http.StatusInternalServerError,
+			expectedAttempts:  1,
+			expectedRetryable: true,
+		},
+		{
+			name:             "POST is retried on a 429",
+			method:           http.MethodPost,
+			payload:          map[string]string{"name": "corgi"},
+			statuses:         []int{http.StatusTooManyRequests, http.StatusOK},
+			retryAfter:       "0",
+			expectedStatus:   http.StatusOK,
+			expectedAttempts: 2,
+		},
+		{
+			name:             "POST is retried when allowed",
+			method:           http.MethodPost,
+			payload:          map[string]string{"name": "corgi"},
+			policy:           RetryPolicy{RetryNonIdempotent: true},
+			statuses:         []int{http.StatusInternalServerError, http.StatusOK},
+			expectedStatus:   http.StatusOK,
+			expectedAttempts: 2,
+		},
+		{
+			name:              "Retry-After longer than max delay isn't waited for",
+			method:            http.MethodGet,
+			policy:            RetryPolicy{MaxDelay: time.Second},
+			statuses:          []int{http.StatusTooManyRequests, http.StatusOK},
+			retryAfter:        "120",
+			expectedStatus:    http.StatusTooManyRequests,
+			expectedAttempts:  1,
+			expectedRetryable: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var attempts atomic.Int32
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				attempt := attempts.Add(1)
+				if tt.payload != nil {
+					// the body has to be sent again on every attempt
+					body, _ := io.ReadAll(r.Body)
+					assert.JSONEq(t, `{"name":"corgi"}`, string(body))
+				}
+				if tt.retryAfter != "" {
+					w.Header().Set("Retry-After", tt.retryAfter)
+				}
+				w.WriteHeader(tt.statuses[attempt-1])
+			}))
+			defer ts.Close()
+
+			tt.policy.BaseDelay = time.Millisecond
+			client := httpClient{BaseURL: ts.URL}
+			WithRetry(tt.policy)(&client)
+
+			resp, err := client.Request(context.Background(), tt.method, "/", tt.payload, nil)
+			assert.Equal(t, tt.expectedStatus, resp.StatusCode)
+			assert.Equal(t, tt.expectedAttempts, attempts.Load())
+			if tt.expectedStatus == http.StatusOK {
+				assert.NoError(t, err)
+				return
+			}
+			assert.Error(t, err)
+			assert.Equal(t, tt.expectedRetryable, isRetryable(err))
+		})
+	}
+}
+
+func TestRetryNetworkError(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	url := ts.URL
+	ts.Close()
+
+	client := httpClient{BaseURL: url}
+	WithRetry(RetryPolicy{MaxAttempts: 2, BaseDelay: time.Millisecond})(&client)
+
+	_, err := client.Request(context.Background(), http.MethodGet, "/", nil, nil)
+	assert.Error(t, err)
+	assert.True(t, isRetryable(err))
+
+	// the upstream could have acted on a POST before the connection failed
+	_, err = client.Request(context.Background(), http.MethodPost, "/", nil, nil)
+	assert.Error(t, err)
+	assert.False(t, isRetryable(err))
+
+	WithRetry(RetryPolicy{MaxAttempts: 2, BaseDelay: time.Millisecond, RetryNonIdempotent: true})(&client)
+	_, err = client.Request(context.Background(), http.MethodPost, "/", nil, nil)
+	assert.Error(t, err)
+	assert.True(t, isRetryable(err))
+
+	// a cancelled caller isn't told to retry
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = client.Request(ctx, http.MethodGet, "/", nil, nil)
+	assert.Error(t, err)
+	assert.True(t, errors.Is(err, context.Canceled))
+	assert.False(t, isRetryable(err))
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	tests := []struct {
+		name     string
+		value    string
+		expected time.Duration
+		found    bool
+	}{
+		{name: "empty", value: "", found: false},
+		{name: "seconds", value: "3", expected: 3 * time.Second, found: true},
+		{name: "date in the past", value: "Wed, 21 Oct 2015 07:28:00 GMT", expected: 0, found: true},
+		{name: "garbage", value: "soon", found: false},
+		{name: "negative", value: "-1", found: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			delay, found := parseRetryAfter(tt.value)
+			assert.Equal(t, tt.found, found)
+			assert.Equal(t, tt.expected, delay)
+		})
+	}
+
+	delay, found := parseRetryAfter(time.Now().Add(time.Minute).UTC().Format(http.TimeFormat))
+	assert.True(t, found)
+	assert.InDelta(t, time.Minute, delay, float64(2*time.Second))
+}
+
+func TestBackoff(t *testing.T) {
+	policy := RetryPolicy{BaseDelay: 100 * time.Millisecond, MaxDelay: time.Second}
+	for attempt := 1; attempt <= 6; attempt++ {
+		delay, ok := policy.backoff(attempt, nil)
+		expected := min(policy.BaseDelay<<(attempt-1), policy.MaxDelay)
+		assert.True(t, ok)
+		assert.GreaterOrEqual(t, delay, expected/2)
+		assert.LessOrEqual(t, delay, expected)
+	}
+}
+
+func isRetryable(err error) bool {
+	var derr *derror.Error
+	return errors.As(err, &derr) && derr.IsRetryable()
+}
@@ -0,0 +1,124 @@
+package httpclient
+
+import (
+	"context"
+	"crypto/tls"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// WithHTTPClient sends requests with a copy of the client instead of the default one, nil keeps the default one.
+// Options after it that change the transport only apply if its transport is an *http.Transport
+func WithHTTPClient(client *http.Client) Option {
+	return func(hc *httpClient) {
+		if client == nil {
+			hc.client = newHTTPClient()
+			hc.ownsTransport = true
+			return
+		}
+
+		copied := *client
+		hc.client = &copied
+		hc.ownsTransport = false
+	}
+}
+
+// WithTransport sends requests through the RoundTripper
+func WithTransport(rt http.RoundTripper) Option {
+	return func(hc *httpClient) {
+		hc.httpClient().Transport = rt
+		hc.ownsTransport = false
+	}
+}
+
+// WithRequestTimeout limits each attempt, including reading the body. Retries get their own timeout
+func WithRequestTimeout(timeout time.Duration) Option {
+	return func(hc *httpClient) {
+		hc.httpClient().Timeout = timeout
+	}
+}
+
+// WithTimeout limits the whole call including retries and reading the body, the body has to be closed to release it
+func WithTimeout(timeout time.Duration) Option {
+	return func(hc *httpClient) {
+		hc.timeout = timeout
+	}
+}
+
+// WithMaxIdleConnsPerHost sets how many idle connections are kept per host, net/http only keeps 2 by default
+func WithMaxIdleConnsPerHost(n int) Option {
+	return func(hc *httpClient) {
+		if transport := hc.transport(); transport != nil {
+			transport.MaxIdleConnsPerHost = n
+			transport.MaxIdleConns = max(transport.MaxIdleConns, n)
+		}
+	}
+}
+
+// WithProxy sends every request through the proxy instead of the one from the environment
+func WithProxy(proxyURL *url.URL) Option {
+	return func(hc *httpClient) {
+		if transport := hc.transport(); transport != nil {
+			transport.Proxy = http.ProxyURL(proxyURL)
+		}
+	}
+}
+
+// WithTLSConfig sets the TLS config, e.g. RootCAs for a private CA or Certificates for mTLS
+func WithTLSConfig(config *tls.Config) Option {
+	return func(hc *httpClient) {
+		if transport := hc.transport(); transport != nil {
+			transport.TLSClientConfig = config
+		}
+	}
+}
+
+// newHTTPClient is the client New starts with, it has its own connection pool
+func newHTTPClient() *http.Client {
+	return &http.Client{Transport: http.DefaultTransport.(*http.Transport).Clone()}
+}
+
+// httpClient returns the client requests are sent with, setting one up if there isn't one yet
+func (hc *httpClient) httpClient() *http.Client {
+	if hc.client == nil {
+		hc.client = newHTTPClient()
+		hc.ownsTransport = true
+	}
+	return hc.client
+}
+
+// transport returns an *http.Transport that can be changed without affecting anyone else,
+// nil if the client uses some other RoundTripper
+func (hc *httpClient) transport() *http.Transport {
+	client := hc.httpClient()
+	if hc.ownsTransport {
+		return client.Transport.(*http.Transport)
+	}
+
+	switch transport := client.Transport.(type) {
+	case nil:
+		client.Transport = http.DefaultTransport.(*http.Transport).Clone()
+	case *http.Transport:
+		client.Transport = transport.Clone()
+	default:
+		log.Warn().Msg("transport option ignored, the client's transport isn't an *http.Transport")
+		return nil
+	}
+	hc.ownsTransport = true
+	return client.Transport.(*http.Transport)
+}
+
+// cancelBody cancels the context of the call once the body is closed
+type cancelBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelBody) Close() error {
+	defer b.cancel()
+	return b.ReadCloser.Close()
+}
@@ -0,0 +1,151 @@
+package httpclient
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTransportOptions(t *testing.T) {
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(time.Second):
+		}
+	}))
+	defer slow.Close()
+
+	tlsServer := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("secure"))
+	}))
+	defer tlsServer.Close()
+
+	proxied := false
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// proxies get the absolute URL
+		proxied = r.URL.Host == "upstream.invalid"
+		w.Write([]byte("proxied"))
+	}))
+	defer proxy.Close()
+	proxyURL, _ := url.Parse(proxy.URL)
+
+	tests := []struct {
+		name         string
+		baseURL      string
+		opts         []Option
+		expectedErr  bool
+		expectedBody string
+	}{
+		{
+			name:    "custom RoundTripper",
+			baseURL: "http://upstream.invalid",
+			opts: []Option{WithTransport(RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+				return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader("stubbed")), Request: req}, nil
+			}))},
+			expectedBody: "stubbed",
+		},
+		{
+			name:        "request timeout",
+			baseURL:     slow.URL,
+			opts:        []Option{WithRequestTimeout(20 * time.Millisecond)},
+			expectedErr: true,
+		},
+		{
+			name:        "overall timeout covers retries",
+			baseURL:     slow.URL,
+			opts:        []Option{WithTimeout(50 * time.Millisecond), WithRequestTimeout(20 * time.Millisecond), WithRetry(RetryPolicy{MaxAttempts: 100, BaseDelay: time.Millisecond})},
+			expectedErr: true,
+		},
+		{
+			name:        "unknown CA",
+			baseURL:     tlsServer.URL,
+			expectedErr: true,
+		},
+		{
+			name:         "TLS config",
+			baseURL:      tlsServer.URL,
+			opts:         []Option{WithTLSConfig(tlsServer.Client().Transport.(*http.Transport).TLSClientConfig)},
+			expectedBody: "secure",
+		},
+		{
+			name:         "proxy",
+			baseURL:      "http://upstream.invalid",
+			opts:         []Option{WithProxy(proxyURL), WithMaxIdleConnsPerHost(10)},
+			expectedBody: "proxied",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := New(tt.baseURL, "", tt.opts...)
+
+			start := time.Now()
+			resp, err := client.Get(context.Background(), "/", nil)
+			assert.Less(t, time.Since(start), 500*time.Millisecond)
+			if tt.expectedErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			defer resp.Body.Close()
+
+			body, err := io.ReadAll(resp.Body)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expectedBody, string(body))
+		})
+	}
+	assert.True(t, proxied)
+}
+
+func TestWithHTTPClient(t *testing.T) {
+	base := &http.Client{Transport: http.DefaultTransport.(*http.Transport).Clone()}
+	transport := base.Transport.(*http.Transport)
+
+	hc := &httpClient{}
+	WithHTTPClient(base)(hc)
+	WithMaxIdleConnsPerHost(50)(hc)
+	WithRequestTimeout(time.Second)(hc)
+
+	// the caller's client and transport are left alone
+	assert.Zero(t, base.Timeout)
+	assert.Zero(t, transport.MaxIdleConnsPerHost)
+	assert.Equal(t, time.Second, hc.client.Timeout)
+	assert.Equal(t, 50, hc.client.Transport.(*http.Transport).MaxIdleConnsPerHost)
+
+	// transport options can't change a RoundTripper they don't know
+	WithTransport(RoundTripperFunc(http.DefaultTransport.RoundTrip))(hc)
+	WithMaxIdleConnsPerHost(10)(hc)
+	_, isTransport := hc.client.Transport.(*http.Transport)
+	assert.False(t, isTransport)
+
+	// nil goes back to a default client the transport options can change
+	WithHTTPClient(nil)(hc)
+	WithMaxIdleConnsPerHost(10)(hc)
+	assert.True(t, hc.ownsTransport)
+	assert.Equal(t, 10, hc.client.Transport.(*http.Transport).MaxIdleConnsPerHost)
+}
+
+func TestWithTimeoutBody(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("body"))
+	}))
+	defer ts.Close()
+
+	client := New(ts.URL, "", WithTimeout(time.Second))
+	resp, err := client.Get(context.Background(), "/", nil)
+	assert.NoError(t, err)
+
+	// the timeout's context is only cancelled once the body is closed
+	body, err := io.ReadAll(resp.Body)
+	assert.NoError(t, err)
+	assert.Equal(t, "body", string(body))
+	assert.NoError(t, resp.Body.Close())
+	assert.Error(t, resp.Request.Context().Err())
+}
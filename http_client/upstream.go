@@ -0,0 +1,103 @@
+package httpclient
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/meowmix1337/go-core/derror"
+)
+
+// MaxErrorBodySize is how much of a non-2xx body is kept on the UpstreamError
+const MaxErrorBodySize = 4 << 10 // 4KB
+
+// maxErrorMessageBody is how much of the body Error includes, so logging the error doesn't log the whole body
+const maxErrorMessageBody = 256
+
+// UpstreamError is the Err of the derror returned for a non-2xx response
+type UpstreamError struct {
+	Method     string
+	URL        string // without the query, it can hold secrets
+	StatusCode int
+	Header     http.Header
+	Body       []byte // at most MaxErrorBodySize
+	Truncated  bool   // the body was longer than Body
+}
+
+// Error includes the start of the body, the rest is only on Body
+func (e *UpstreamError) Error() string {
+	msg := fmt.Sprintf("%s %s returned %d", e.Method, e.URL, e.StatusCode)
+	body := e.Body[:min(len(e.Body), maxErrorMessageBody)]
+	if len(body) > 0 {
+		// a cut can split a multi-byte character
+		msg += ": " + strings.ToValidUTF8(string(body), "")
+	}
+	if e.Truncated || len(e.Body) > len(body) {
+		msg += "..."
+	}
+	return msg
+}
+
+// upstreamError maps the response's status to a derror. The start of the body is put back so it can still be read
+func upstreamError(ctx context.Context, req *http.Request, resp *http.Response) *derror.Error {
+	body, err := io.ReadAll(io.LimitReader(resp.Body, MaxErrorBodySize+1))
+	truncated := len(body) > MaxErrorBodySize
+	var rest io.Reader = resp.Body
+	if err != nil {
+		rest = errReader{err}
+	}
+	resp.Body = readCloser{io.MultiReader(bytes.NewReader(body), rest), resp.Body}
+
+	url := *req.URL
+	url.RawQuery, url.User = "", nil
+	upstream := &UpstreamError{
+		Method:     req.Method,
+		URL:        url.String(),
+		StatusCode: resp.StatusCode,
+		Header:     resp.Header,
+		Body:       body[:min(len(body), MaxErrorBodySize)],
+		Truncated:  truncated,
+	}
+
+	code, errType := statusError(resp.StatusCode)
+	if retryableStatus(resp.StatusCode) {
+		return derror.NewRetryable(ctx, code, errType, "request response received a bad status code", upstream)
+	}
+	return derror.New(ctx, code, errType, "request response received a bad status code", upstream)
+}
+
+// statusError picks the derror code and type for an upstream status
+func statusError(status int) (derror.Code, derror.Type) {
+	switch status {
+	case http.StatusBadRequest, http.StatusUnprocessableEntity:
+		return derror.BadRequestCode, derror.BadRequestType
+	case http.StatusUnauthorized:
+		return derror.UnauthorizedCode, derror.UnauthorizedType
+	case http.StatusForbidden:
+		return derror.ForbiddenCode, derror.ForbiddenType
+	case http.StatusNotFound, http.StatusGone:
+		return derror.NotFoundCode, derror.NotFoundType
+	case http.StatusConflict, http.StatusPreconditionFailed:
+		return derror.ConflictCode, derror.ConflictType
+	case http.StatusTooManyRequests:
+		return derror.TooManyRequestsCode, derror.RateLimitedType
+	case http.StatusInternalServerError:
+		return derror.InternalServerCode, derror.InternalType
+	case http.StatusServiceUnavailable:
+		return derror.ServiceUnavailableCode, derror.UnavailableType
+	case http.StatusGatewayTimeout:
+		return derror.GatewayTimeoutCode, derror.TimeoutType
+	}
+
+	switch {
+	case status >= 500:
+		return derror.BadGatewayCode, derror.UpstreamType
+	case status >= 400:
+		return derror.BadRequestCode, derror.BadRequestType
+	}
+	// e.g. a redirect that wasn't followed
+	return derror.BadGatewayCode, derror.UpstreamType
+}
@@ -0,0 +1,103 @@
+package httpclient
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/meowmix1337/go-core/derror"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUpstreamError(t *testing.T) {
+	tests := []struct {
+		name              string
+		status            int
+		body              string
+		expectedCode      derror.Code
+		expectedType      derror.Type
+		expectedRetryable bool
+		expectedTruncated bool
+	}{
+		{name: "400", status: http.StatusBadRequest, body: `{"error":"bad"}`, expectedCode: derror.BadRequestCode, expectedType: derror.BadRequestType},
+		{name: "401", status: http.StatusUnauthorized, expectedCode: derror.UnauthorizedCode, expectedType: derror.UnauthorizedType},
+		{name: "403", status: http.StatusForbidden, expectedCode: derror.ForbiddenCode, expectedType: derror.ForbiddenType},
+		{name: "404", status: http.StatusNotFound, expectedCode: derror.NotFoundCode, expectedType: derror.NotFoundType},
+		{name: "409", status: http.StatusConflict, expectedCode: derror.ConflictCode, expectedType: derror.ConflictType},
+		{name: "other 4xx", status: http.StatusTeapot, expectedCode: derror.BadRequestCode, expectedType: derror.BadRequestType},
+		{name: "429", status: http.StatusTooManyRequests, expectedCode: derror.TooManyRequestsCode, expectedType: derror.RateLimitedType, expectedRetryable: true},
+		{name: "500", status: http.StatusInternalServerError, expectedCode: derror.InternalServerCode, expectedType: derror.InternalType, expectedRetryable: true},
+		{name: "502", status: http.StatusBadGateway, expectedCode: derror.BadGatewayCode, expectedType: derror.UpstreamType, expectedRetryable: true},
+		{name: "503", status: http.StatusServiceUnavailable, expectedCode: derror.ServiceUnavailableCode, expectedType: derror.UnavailableType, expectedRetryable: true},
+		{name: "504", status: http.StatusGatewayTimeout, expectedCode: derror.GatewayTimeoutCode, expectedType: derror.TimeoutType, expectedRetryable: true},
+		{name: "long body is truncated", status: http.StatusBadRequest, body: strings.Repeat("a", MaxErrorBodySize+10), expectedCode: derror.BadRequestCode, expectedType: derror.BadRequestType, expectedTruncated: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("X-Request-Id", "abc")
+				w.WriteHeader(tt.status)
+				w.Write([]byte(tt.body))
+			}))
+			defer ts.Close()
+
+			c := &httpClient{BaseURL: ts.URL, APIPrefix: "/api"}
+			resp, err := c.Request(context.Background(), http.MethodPost, "/breeds", nil, map[string]string{"token": "secret"})
+
+			var derr *derror.Error
+			assert.True(t, errors.As(err, &derr))
+			assert.Equal(t, tt.expectedCode, derr.Code)
+			assert.Equal(t, tt.expectedType, derr.Type)
+			assert.Equal(t, tt.expectedRetryable, derr.IsRetryable())
+
+			var upstream *UpstreamError
+			assert.True(t, errors.As(err, &upstream))
+			assert.Equal(t, http.MethodPost, upstream.Method)
+			assert.Equal(t, ts.URL+"/api/breeds", upstream.URL)
+			assert.Equal(t, tt.status, upstream.StatusCode)
+			assert.Equal(t, "abc", upstream.Header.Get("X-Request-Id"))
+			assert.Equal(t, tt.expectedTruncated, upstream.Truncated)
+			assert.Equal(t, tt.body[:min(len(tt.body), MaxErrorBodySize)], string(upstream.Body))
+			assert.Contains(t, upstream.Error(), tt.body[:min(len(tt.body), maxErrorMessageBody)])
+			assert.LessOrEqual(t, len(upstream.Error()), len(upstream.URL)+maxErrorMessageBody+32)
+
+			// the whole body can still be read
+			body, err := io.ReadAll(resp.Body)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.body, string(body))
+			resp.Body.Close()
+		})
+	}
+}
+
+func TestUpstreamErrorMessage(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      UpstreamError
+		expected string
+	}{
+		{name: "no body", err: UpstreamError{Method: "GET", URL: "http://dog.ceo/api", StatusCode: 404}, expected: "GET http://dog.ceo/api returned 404"},
+		{name: "short body", err: UpstreamError{Method: "GET", URL: "http://dog.ceo/api", StatusCode: 400, Body: []byte("bad")}, expected: "GET http://dog.ceo/api returned 400: bad"},
+		{
+			name:     "long body is cut",
+			err:      UpstreamError{Method: "GET", URL: "http://dog.ceo/api", StatusCode: 400, Body: []byte(strings.Repeat("a", maxErrorMessageBody+1))},
+			expected: "GET http://dog.ceo/api returned 400: " + strings.Repeat("a", maxErrorMessageBody) + "...",
+		},
+		{
+			name:     "cut in a character",
+			err:      UpstreamError{Method: "GET", URL: "http://dog.ceo/api", StatusCode: 400, Body: []byte(strings.Repeat("a", maxErrorMessageBody-1) + "é")},
+			expected: "GET http://dog.ceo/api returned 400: " + strings.Repeat("a", maxErrorMessageBody-1) + "...",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, tt.err.Error())
+		})
+	}
+}
@@ -0,0 +1,331 @@
+package http_util
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"net/textproto"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/meowmix1337/go-core/cache"
+	"github.com/rs/zerolog/log"
+)
+
+const DefaultMaxBodySize = 1 << 20 // 1MB
+
+// KeyFunc returns the cache key for a request, requests with the same key share a cached response
+type KeyFunc func(r *http.Request) string
+
+// DefaultKeyFunc keys responses by host, path and query
+func DefaultKeyFunc(r *http.Request) string {
+	return "http:" + r.Host + r.URL.RequestURI()
+}
+
+// CacheOption configures CacheMiddleware
+type CacheOption func(*cacheOptions)
+
+type cacheOptions struct {
+	keyFunc     KeyFunc
+	defaultTTL  time.Duration
+	maxBodySize int
+}
+
+// WithKeyFunc changes how requests are keyed, e.g. to leave out tracking query params or to add the user
+func WithKeyFunc(fn KeyFunc) CacheOption {
+	return func(o *cacheOptions) {
+		o.keyFunc = fn
+	}
+}
+
+// WithDefaultTTL caches responses that don't set max-age or Expires for the ttl.
+// By default those responses aren't cached
+func WithDefaultTTL(ttl time.Duration) CacheOption {
+	return func(o *cacheOptions) {
+		o.defaultTTL = ttl
+	}
+}
+
+// WithMaxBodySize skips caching responses with a body larger than size bytes, DefaultMaxBodySize by default
+func WithMaxBodySize(size int) CacheOption {
+	return func(o *cacheOptions) {
+		o.maxBodySize = size
+	}
+}
+
+// cachedResponse is what gets stored. A response that varies on request headers is stored under a key
+// including those headers, the request's own key only holds the names of the headers (Vary)
+type cachedResponse struct {
+	Vary     []string    `json:"vary,omitempty"`
+	Status   int         `json:"status,omitempty"`
+	Header   http.Header `json:"header,omitempty"`
+	Body     []byte      `json:"body,omitempty"`
+	StoredAt time.Time   `json:"stored_at"`
+}
+
+// CacheMiddleware caches GET responses in any cache backend, shared by everyone calling the handler.
+// Responses are cached for their Cache-Control max-age (or s-maxage, or Expires). no-store, private, no-cache,
+// Set-Cookie and Vary: * responses are never cached. Requests with Authorization or Cache-Control: no-cache/no-store
+// skip the cache. A cached response with an ETag matching If-None-Match is answered with 304 Not Modified
+func CacheMiddleware(c cache.Cache, opts ...CacheOption) func(http.Handler) http.Handler {
+	o := &cacheOptions{
+		keyFunc:     DefaultKeyFunc,
+		maxBodySize: DefaultMaxBodySize,
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	responses := cache.NewTypedCache[cachedResponse](c)
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !cacheableRequest(r) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			key := o.keyFunc(r)
+			if cached, found := lookupResponse(r, responses, key); found {
+				writeCachedResponse(w, r, cached)
+				return
+			}
+
+			recorder := &responseRecorder{ResponseWriter: w, maxBodySize: o.maxBodySize}
+			recorder.Header().Set("X-Cache", "MISS")
+			next.ServeHTTP(recorder, r)
+			// handlers that never write still send a 200
+			recorder.WriteHeader(http.StatusOK)
+
+			if recorder.overflow {
+				return
+			}
+
+			ttl, cacheable := responseTTL(recorder.statusCode, recorder.header, o.defaultTTL)
+			if !cacheable {
+				return
+			}
+			storeResponse(r, responses, key, ttl, cachedResponse{
+				Status:   recorder.statusCode,
+				Header:   recorder.header,
+				Body:     recorder.body.Bytes(),
+				StoredAt: time.Now(),
+			})
+		})
+	}
+}
+
+func cacheableRequest(r *http.Request) bool {
+	if r.Method != http.MethodGet || r.Header.Get("Authorization") != "" {
+		return false
+	}
+
+	directives := CacheControl(r.Header)
+	_, noCache := directives["no-cache"]
+	_, noStore := directives["no-store"]
+	return !noCache && !noStore
+}
+
+// lookupResponse follows the request's key to the variant matching its headers
+func lookupResponse(r *http.Request, responses *cache.TypedCache[cachedResponse], key string) (cachedResponse, bool) {
+	cached, err := responses.Get(r.Context(), key)
+	if err == nil && len(cached.Vary) > 0 {
+		cached, err = responses.Get(r.Context(), VariantKey(key, cached.Vary, r.Header))
+	}
+
+	if err != nil {
+		if !errors.Is(err, cache.CacheMissErr) {
+			log.Err(err).Str("key", key).Msg("failed to get cached response")
+		}
+		return cachedResponse{}, false
+	}
+	return cached, true
+}
+
+func storeResponse(r *http.Request, responses *cache.TypedCache[cachedResponse], key string, ttl time.Duration, response cachedResponse) {
+	vary := VaryHeaders(response.Header)
+	if len(vary) > 0 {
+		// the names of the headers live as long as the variant so a lookup never finds one without the other
+		if err := responses.SetTTL(r.Context(), key, cachedResponse{Vary: vary}, ttl); err != nil {
+			log.Err(err).Str("key", key).Msg("failed to cache response vary headers")
+			return
+		}
+		key = VariantKey(key, vary, r.Header)
+	}
+
+	if err := responses.SetTTL(r.Context(), key, response, ttl); err != nil {
+		log.Err(err).Str("key", key).Msg("failed to cache response")
+	}
+}
+
+func writeCachedResponse(w http.ResponseWriter, r *http.Request, cached cachedResponse) {
+	header := w.Header()
+	for name, values := range cached.Header {
+		header[name] = slices.Clone(values)
+	}
+	header.Set("X-Cache", "HIT")
+	header.Set("Age", strconv.Itoa(int(time.Since(cached.StoredAt).Seconds())))
+
+	if etag := cached.Header.Get("ETag"); etag != "" && etagMatches(r.Header.Get("If-None-Match"), etag) {
+		// a 304 has no body, so the headers describing it are left out
+		header.Del("Content-Length")
+		header.Del("Content-Type")
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	w.WriteHeader(cached.Status)
+	w.Write(cached.Body)
+}
+
+// responseTTL returns how long the response can be cached and whether it can be at all
+func responseTTL(status int, header http.Header, defaultTTL time.Duration) (time.Duration, bool) {
+	if !cacheableStatus(status) || header.Get("Set-Cookie") != "" || slices.Contains(VaryHeaders(header), "*") {
+		return 0, false
+	}
+
+	directives := CacheControl(header)
+	for _, directive := range []string{"no-store", "no-cache", "private"} {
+		if _, found := directives[directive]; found {
+			return 0, false
+		}
+	}
+
+	// this is a shared cache so s-maxage wins over max-age
+	for _, directive := range []string{"s-maxage", "max-age"} {
+		if value, found := directives[directive]; found {
+			seconds, err := strconv.Atoi(value)
+			if err != nil || seconds <= 0 {
+				return 0, false
+			}
+			return time.Duration(seconds) * time.Second, true
+		}
+	}
+
+	if expires := header.Get("Expires"); expires != "" {
+		at, err := http.ParseTime(expires)
+		if err != nil {
+			return 0, false
+		}
+		ttl := time.Until(at)
+		return ttl, ttl > 0
+	}
+
+	return defaultTTL, defaultTTL > 0
+}
+
+// cacheableStatus is true for the status codes that are cacheable by default (RFC 9110 section 15.1)
+func cacheableStatus(status int) bool {
+	switch status {
+	case http.StatusOK, http.StatusNonAuthoritativeInfo, http.StatusNoContent, http.StatusMultipleChoices,
+		http.StatusMovedPermanently, http.StatusPermanentRedirect, http.StatusNotFound, http.StatusMethodNotAllowed,
+		http.StatusGone, http.StatusRequestURITooLong, http.StatusNotImplemented:
+		return true
+	}
+	return false
+}
+
+// CacheControl parses the Cache-Control header into its directives, directives without a value map to "".
+// Directive names are lowercased and quoted values unquoted
+func CacheControl(header http.Header) map[string]string {
+	directives := make(map[string]string)
+	for _, value := range header.Values("Cache-Control") {
+		for _, directive := range strings.Split(value, ",") {
+			name, arg, _ := strings.Cut(strings.TrimSpace(directive), "=")
+			if name != "" {
+				directives[strings.ToLower(name)] = strings.Trim(arg, `"`)
+			}
+		}
+	}
+	return directives
+}
+
+// VaryHeaders returns the canonical, sorted names of the headers in Vary
+func VaryHeaders(header http.Header) []string {
+	var names []string
+	for _, value := range header.Values("Vary") {
+		for _, name := range strings.Split(value, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				names = append(names, textproto.CanonicalMIMEHeaderKey(name))
+			}
+		}
+	}
+	slices.Sort(names)
+	return slices.Compact(names)
+}
+
+// VariantKey adds the request's values for the vary headers to the key
+func VariantKey(key string, vary []string, header http.Header) string {
+	var b strings.Builder
+	b.WriteString(key)
+	for _, name := range vary {
+		b.WriteString("|")
+		b.WriteString(name)
+		b.WriteString("=")
+		b.WriteString(strings.Join(header.Values(name), ","))
+	}
+	return b.String()
+}
+
+// etagMatches does the weak comparison If-None-Match calls for
+func etagMatches(ifNoneMatch, etag string) bool {
+	if ifNoneMatch == "" {
+		return false
+	}
+	if strings.TrimSpace(ifNoneMatch) == "*" {
+		return true
+	}
+
+	etag = strings.TrimPrefix(etag, "W/")
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		if strings.TrimPrefix(strings.TrimSpace(candidate), "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// responseRecorder passes the response through to the client while keeping a copy to cache
+type responseRecorder struct {
+	http.ResponseWriter
+	maxBodySize int
+
+	header      http.Header
+	statusCode  int
+	body        bytes.Buffer
+	overflow    bool
+	wroteHeader bool
+}
+
+func (r *responseRecorder) WriteHeader(statusCode int) {
+	if r.wroteHeader {
+		return
+	}
+	r.wroteHeader = true
+	r.statusCode = statusCode
+	// copied now since the handler can keep changing the map after the headers were sent
+	r.header = r.ResponseWriter.Header().Clone()
+	r.header.Del("X-Cache")
+	r.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (r *responseRecorder) Write(b []byte) (int, error) {
+	r.WriteHeader(http.StatusOK)
+
+	if !r.overflow {
+		if r.body.Len()+len(b) > r.maxBodySize {
+			r.overflow = true
+			r.body = bytes.Buffer{}
+		} else {
+			r.body.Write(b)
+		}
+	}
+	return r.ResponseWriter.Write(b)
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer, e.g. to flush
+func (r *responseRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
@@ -0,0 +1,257 @@
+package http_util
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/meowmix1337/go-core/cache"
+	"github.com/stretchr/testify/assert"
+)
+
+// countingHandler writes the body with the headers and counts how often it was called
+func countingHandler(calls *atomic.Int32, header http.Header, body string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		for name, values := range header {
+			w.Header()[name] = values
+		}
+		w.Write([]byte(body))
+	})
+}
+
+func serve(handler http.Handler, method string, header http.Header) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, "/users/42?expand=orders", nil)
+	for name, values := range header {
+		r.Header[name] = values
+	}
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	return w
+}
+
+func TestCacheMiddleware(t *testing.T) {
+	tests := []struct {
+		name           string
+		method         string
+		requestHeader  http.Header
+		responseHeader http.Header
+		opts           []CacheOption
+		expectedCalls  int32
+	}{
+		{
+			name:           "max-age is cached",
+			method:         http.MethodGet,
+			responseHeader: http.Header{"Cache-Control": {"public, max-age=60"}},
+			expectedCalls:  1,
+		},
+		{
+			name:           "s-maxage is cached",
+			method:         http.MethodGet,
+			responseHeader: http.Header{"Cache-Control": {"s-maxage=60"}},
+			expectedCalls:  1,
+		},
+		{
+			name:           "expires is cached",
+			method:         http.MethodGet,
+			responseHeader: http.Header{"Expires": {time.Now().Add(time.Minute).UTC().Format(http.TimeFormat)}},
+			expectedCalls:  1,
+		},
+		{
+			name:          "no cache headers is not cached",
+			method:        http.MethodGet,
+			expectedCalls: 2,
+		},
+		{
+			name:          "no cache headers with a default ttl is cached",
+			method:        http.MethodGet,
+			opts:          []CacheOption{WithDefaultTTL(time.Minute)},
+			expectedCalls: 1,
+		},
+		{
+			name:           "no-store is not cached",
+			method:         http.MethodGet,
+			responseHeader: http.Header{"Cache-Control": {"no-store"}},
+			opts:           []CacheOption{WithDefaultTTL(time.Minute)},
+			expectedCalls:  2,
+		},
+		{
+			name:           "private is not cached",
+			method:         http.MethodGet,
+			responseHeader: http.Header{"Cache-Control": {"private, max-age=60"}},
+			expectedCalls:  2,
+		},
+		{
+			name:           "set-cookie is not cached",
+			method:         http.MethodGet,
+			responseHeader: http.Header{"Cache-Control": {"max-age=60"}, "Set-Cookie": {"session=1"}},
+			expectedCalls:  2,
+		},
+		{
+			name:           "vary * is not cached",
+			method:         http.MethodGet,
+			responseHeader: http.Header{"Cache-Control": {"max-age=60"}, "Vary": {"*"}},
+			expectedCalls:  2,
+		},
+		{
+			name:           "post is not cached",
+			method:         http.MethodPost,
+			responseHeader: http.Header{"Cache-Control": {"max-age=60"}},
+			expectedCalls:  2,
+		},
+		{
+			name:           "request no-cache skips the cache",
+			method:         http.MethodGet,
+			requestHeader:  http.Header{"Cache-Control": {"no-cache"}},
+			responseHeader: http.Header{"Cache-Control": {"max-age=60"}},
+			expectedCalls:  2,
+		},
+		{
+			name:           "authorization skips the cache",
+			method:         http.MethodGet,
+			requestHeader:  http.Header{"Authorization": {"Bearer token"}},
+			responseHeader: http.Header{"Cache-Control": {"max-age=60"}},
+			expectedCalls:  2,
+		},
+		{
+			name:           "body over the max size is not cached",
+			method:         http.MethodGet,
+			responseHeader: http.Header{"Cache-Control": {"max-age=60"}},
+			opts:           []CacheOption{WithMaxBodySize(4)},
+			expectedCalls:  2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var calls atomic.Int32
+			handler := CacheMiddleware(cache.NewLRUCache(100), tt.opts...)(countingHandler(&calls, tt.responseHeader, "hello"))
+
+			first := serve(handler, tt.method, tt.requestHeader)
+			second := serve(handler, tt.method, tt.requestHeader)
+
+			assert.Equal(t, tt.expectedCalls, calls.Load())
+			assert.Equal(t, "hello", first.Body.String())
+			assert.Equal(t, "hello", second.Body.String())
+			assert.Equal(t, first.Header().Get("Content-Type"), second.Header().Get("Content-Type"))
+			if tt.expectedCalls == 1 {
+				assert.Equal(t, "MISS", first.Header().Get("X-Cache"))
+				assert.Equal(t, "HIT", second.Header().Get("X-Cache"))
+			}
+		})
+	}
+}
+
+func TestCacheMiddleware_Status(t *testing.T) {
+	var calls atomic.Int32
+	handler := CacheMiddleware(cache.NewLRUCache(100))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.Header().Set("Cache-Control", "max-age=60")
+		JSONResponse(w, http.StatusNotFound, map[string]string{"error": "not found"})
+	}))
+
+	serve(handler, http.MethodGet, nil)
+	w := serve(handler, http.MethodGet, nil)
+
+	assert.Equal(t, int32(1), calls.Load())
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+	assert.JSONEq(t, `{"error":"not found"}`, w.Body.String())
+}
+
+func TestCacheMiddleware_Expires(t *testing.T) {
+	var calls atomic.Int32
+	handler := CacheMiddleware(cache.NewLRUCache(100), WithDefaultTTL(10*time.Millisecond))(countingHandler(&calls, nil, "hello"))
+
+	serve(handler, http.MethodGet, nil)
+	time.Sleep(20 * time.Millisecond)
+	serve(handler, http.MethodGet, nil)
+
+	assert.Equal(t, int32(2), calls.Load())
+}
+
+func TestCacheMiddleware_ETag(t *testing.T) {
+	var calls atomic.Int32
+	header := http.Header{"Cache-Control": {"max-age=60"}, "Etag": {`"v1"`}, "Content-Type": {"text/plain"}}
+	handler := CacheMiddleware(cache.NewLRUCache(100))(countingHandler(&calls, header, "hello"))
+
+	serve(handler, http.MethodGet, nil)
+
+	w := serve(handler, http.MethodGet, http.Header{"If-None-Match": {`W/"v0", "v1"`}})
+	assert.Equal(t, http.StatusNotModified, w.Code)
+	assert.Empty(t, w.Body.String())
+	assert.Equal(t, `"v1"`, w.Header().Get("ETag"))
+	assert.Empty(t, w.Header().Get("Content-Type"))
+
+	w = serve(handler, http.MethodGet, http.Header{"If-None-Match": {`"v0"`}})
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "hello", w.Body.String())
+
+	assert.Equal(t, int32(1), calls.Load())
+}
+
+func TestCacheMiddleware_Vary(t *testing.T) {
+	var calls atomic.Int32
+	handler := CacheMiddleware(cache.NewLRUCache(100))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.Header().Set("Cache-Control", "max-age=60")
+		w.Header().Set("Vary", "Accept-Language")
+		w.Write([]byte("hello in " + r.Header.Get("Accept-Language")))
+	}))
+
+	english := http.Header{"Accept-Language": {"en"}}
+	french := http.Header{"Accept-Language": {"fr"}}
+
+	assert.Equal(t, "hello in en", serve(handler, http.MethodGet, english).Body.String())
+	assert.Equal(t, "hello in fr", serve(handler, http.MethodGet, french).Body.String())
+	assert.Equal(t, "hello in en", serve(handler, http.MethodGet, english).Body.String())
+	assert.Equal(t, "hello in fr", serve(handler, http.MethodGet, french).Body.String())
+
+	assert.Equal(t, int32(2), calls.Load())
+}
+
+func TestCacheMiddleware_KeyFunc(t *testing.T) {
+	var calls atomic.Int32
+	header := http.Header{"Cache-Control": {"max-age=60"}}
+	pathOnly := func(r *http.Request) string {
+		return r.URL.Path
+	}
+	handler := CacheMiddleware(cache.NewLRUCache(100), WithKeyFunc(pathOnly))(countingHandler(&calls, header, "hello"))
+
+	for _, target := range []string{"/users?utm_source=a", "/users?utm_source=b"} {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, target, nil))
+	}
+
+	assert.Equal(t, int32(1), calls.Load())
+}
+
+func TestEtagMatches(t *testing.T) {
+	tests := []struct {
+		name        string
+		ifNoneMatch string
+		etag        string
+		expected    bool
+	}{
+		{name: "empty", ifNoneMatch: "", etag: `"v1"`, expected: false},
+		{name: "exact", ifNoneMatch: `"v1"`, etag: `"v1"`, expected: true},
+		{name: "weak", ifNoneMatch: `W/"v1"`, etag: `"v1"`, expected: true},
+		{name: "list", ifNoneMatch: `"v0", "v1"`, etag: `W/"v1"`, expected: true},
+		{name: "any", ifNoneMatch: "*", etag: `"v1"`, expected: true},
+		{name: "different", ifNoneMatch: `"v0"`, etag: `"v1"`, expected: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, etagMatches(tt.ifNoneMatch, tt.etag))
+		})
+	}
+}
+
+func TestVaryHeaders(t *testing.T) {
+	header := http.Header{"Vary": {"accept-language, Accept", "Accept-Language"}}
+	assert.Equal(t, []string{"Accept", "Accept-Language"}, VaryHeaders(header))
+	assert.True(t, strings.HasPrefix(VariantKey("key", VaryHeaders(header), http.Header{"Accept": {"text/html"}}), "key|Accept=text/html|"))
+}
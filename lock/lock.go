@@ -0,0 +1,254 @@
+package lock
+
+import (
+	"context"
+	crand "crypto/rand"
+	"encoding/hex"
+	"errors"
+	"math/rand/v2"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// MinTTL is the shortest lease, redis counts in milliseconds
+const MinTTL = time.Millisecond
+
+var (
+	NotAcquiredErr = errors.New("lock is held by someone else")
+	NotHeldErr     = errors.New("lock is no longer held")
+	InvalidTTLErr  = errors.New("lock ttl must be at least 1ms")
+)
+
+// Locker hands out locks by key, every instance sharing the same store competes for the same keys
+type Locker interface {
+	// TryAcquire makes a single attempt at the lock, NotAcquiredErr if someone else holds it
+	TryAcquire(ctx context.Context, key string, ttl time.Duration) (*Lock, error)
+
+	// Acquire retries with backoff until the lock is taken or the context is done
+	Acquire(ctx context.Context, key string, ttl time.Duration) (*Lock, error)
+
+	// WithLock runs fn while holding the lock and releases it afterwards.
+	// The context passed to fn is cancelled if the lock is lost
+	WithLock(ctx context.Context, key string, ttl time.Duration, fn func(ctx context.Context) error) error
+}
+
+// store keeps the leases, a lease is only changed by the holder of its token
+type store interface {
+	// obtain takes the lease if nobody holds it
+	obtain(ctx context.Context, key, token string, ttl time.Duration) (bool, error)
+
+	// extend resets the lease's ttl if it is still held with the token
+	extend(ctx context.Context, key, token string, ttl time.Duration) (bool, error)
+
+	// release gives up the lease if it is still held with the token
+	release(ctx context.Context, key, token string) (bool, error)
+}
+
+type locker struct {
+	store store
+	opts  *options
+}
+
+func newLocker(s store, opts []Option) *locker {
+	return &locker{
+		store: s,
+		opts:  newOptions(opts),
+	}
+}
+
+// TryAcquire makes a single attempt at the lock, NotAcquiredErr if someone else holds it
+func (l *locker) TryAcquire(ctx context.Context, key string, ttl time.Duration) (*Lock, error) {
+	if ttl < MinTTL {
+		return nil, InvalidTTLErr
+	}
+
+	token, err := newToken()
+	if err != nil {
+		return nil, err
+	}
+
+	obtained, err := l.store.obtain(ctx, key, token, ttl)
+	if err != nil {
+		return nil, err
+	}
+	if !obtained {
+		return nil, NotAcquiredErr
+	}
+
+	return newLock(l.store, key, token, ttl, l.opts.autoExtend), nil
+}
+
+// Acquire retries with backoff until the lock is taken or the context is done
+func (l *locker) Acquire(ctx context.Context, key string, ttl time.Duration) (*Lock, error) {
+	backoff := l.opts.minBackoff
+	for {
+		lock, err := l.TryAcquire(ctx, key, ttl)
+		if !errors.Is(err, NotAcquiredErr) {
+			return lock, err
+		}
+
+		// jitter so instances waiting on the same key don't retry in lockstep
+		wait := backoff/2 + rand.N(backoff/2+1)
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+
+		backoff = min(backoff*2, l.opts.maxBackoff)
+	}
+}
+
+// WithLock runs fn while holding the lock and releases it afterwards.
+// The context passed to fn is cancelled if the lock is lost
+func (l *locker) WithLock(ctx context.Context, key string, ttl time.Duration, fn func(ctx context.Context) error) error {
+	lock, err := l.Acquire(ctx, key, ttl)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go func() {
+		select {
+		case <-lock.Lost():
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	defer func() {
+		// the caller's context might be done, releasing shouldn't depend on it
+		if err := lock.Release(context.WithoutCancel(ctx)); err != nil {
+			log.Err(err).Str("key", key).Msg("failed to release lock")
+		}
+	}()
+
+	return fn(ctx)
+}
+
+// newToken returns a random token that identifies a single acquisition of a lock
+func newToken() (string, error) {
+	b := make([]byte, 16)
+	if _, err := crand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// Lock is a held lock. While auto extend is on the lease is extended in the background until Release
+type Lock struct {
+	store store
+	key   string
+	token string
+	ttl   time.Duration
+
+	lost     chan struct{}
+	stop     chan struct{}
+	done     chan struct{}
+	stopOnce sync.Once
+}
+
+func newLock(s store, key, token string, ttl time.Duration, autoExtend bool) *Lock {
+	l := &Lock{
+		store: s,
+		key:   key,
+		token: token,
+		ttl:   ttl,
+		lost:  make(chan struct{}),
+		stop:  make(chan struct{}),
+		done:  make(chan struct{}),
+	}
+
+	if autoExtend {
+		go l.keepAlive()
+	} else {
+		close(l.done)
+	}
+	return l
+}
+
+// Key returns the key the lock was acquired for
+func (l *Lock) Key() string {
+	return l.key
+}
+
+// Lost is closed once the background extension finds the lease was taken over or expired
+func (l *Lock) Lost() <-chan struct{} {
+	return l.lost
+}
+
+// Extend resets the lease to the ttl, NotHeldErr if it was lost
+func (l *Lock) Extend(ctx context.Context, ttl time.Duration) error {
+	if ttl < MinTTL {
+		return InvalidTTLErr
+	}
+
+	extended, err := l.store.extend(ctx, l.key, l.token, ttl)
+	if err != nil {
+		return err
+	}
+	if !extended {
+		return NotHeldErr
+	}
+	return nil
+}
+
+// Release stops extending the lease and gives it up, NotHeldErr if it was already lost or released
+func (l *Lock) Release(ctx context.Context) error {
+	l.stopOnce.Do(func() {
+		close(l.stop)
+	})
+	<-l.done
+
+	released, err := l.store.release(ctx, l.key, l.token)
+	if err != nil {
+		return err
+	}
+	if !released {
+		return NotHeldErr
+	}
+	return nil
+}
+
+// keepAlive extends the lease every third of the ttl. Failed attempts are retried until the lease would have run out
+func (l *Lock) keepAlive() {
+	defer close(l.done)
+
+	interval := l.ttl / 3
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	extendedAt := time.Now()
+	for {
+		select {
+		case <-l.stop:
+			return
+		case <-ticker.C:
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), interval)
+		extended, err := l.store.extend(ctx, l.key, l.token, l.ttl)
+		cancel()
+
+		switch {
+		case err == nil && extended:
+			extendedAt = time.Now()
+			continue
+		case err == nil:
+			log.Warn().Str("key", l.key).Msg("lock was lost")
+		case time.Since(extendedAt) < l.ttl:
+			log.Err(err).Str("key", l.key).Msg("failed to extend lock, retrying")
+			continue
+		default:
+			log.Err(err).Str("key", l.key).Msg("failed to extend lock before it expired")
+		}
+
+		close(l.lost)
+		return
+	}
+}
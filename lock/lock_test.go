@@ -0,0 +1,184 @@
+package lock
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type LockTestSuite struct {
+	suite.Suite
+	locker *locker
+	store  *memoryStore
+}
+
+func TestLockSuite(t *testing.T) {
+	suite.Run(t, new(LockTestSuite))
+}
+
+// SetupTest runs before each test in the suite
+func (s *LockTestSuite) SetupTest() {
+	s.locker = NewMemoryLocker(WithBackoff(time.Millisecond, 5*time.Millisecond))
+	s.store = s.locker.store.(*memoryStore)
+}
+
+func (s *LockTestSuite) TestTryAcquire() {
+	ctx := context.Background()
+
+	lock, err := s.locker.TryAcquire(ctx, "job", time.Minute)
+	s.NoError(err)
+	s.Equal("job", lock.Key())
+
+	_, err = s.locker.TryAcquire(ctx, "job", time.Minute)
+	s.ErrorIs(err, NotAcquiredErr)
+
+	// other keys are independent
+	other, err := s.locker.TryAcquire(ctx, "other job", time.Minute)
+	s.NoError(err)
+	s.NoError(other.Release(ctx))
+
+	s.NoError(lock.Release(ctx))
+	lock, err = s.locker.TryAcquire(ctx, "job", time.Minute)
+	s.NoError(err)
+	s.NoError(lock.Release(ctx))
+}
+
+func (s *LockTestSuite) TestTryAcquire_InvalidTTL() {
+	for _, ttl := range []time.Duration{0, time.Nanosecond, MinTTL - 1} {
+		_, err := s.locker.TryAcquire(context.Background(), "job", ttl)
+		s.ErrorIs(err, InvalidTTLErr)
+	}
+
+	// the lease can run out before the release on a busy machine, only acquiring matters here
+	lock, err := s.locker.TryAcquire(context.Background(), "job", MinTTL)
+	s.NoError(err)
+	lock.Release(context.Background())
+}
+
+func (s *LockTestSuite) TestRelease_Twice() {
+	ctx := context.Background()
+	lock, err := s.locker.TryAcquire(ctx, "job", time.Minute)
+	s.NoError(err)
+
+	s.NoError(lock.Release(ctx))
+	s.ErrorIs(lock.Release(ctx), NotHeldErr)
+}
+
+func (s *LockTestSuite) TestRelease_AfterExpiry() {
+	ctx := context.Background()
+	locker := NewMemoryLocker(WithAutoExtend(false))
+
+	lock, err := locker.TryAcquire(ctx, "job", 5*time.Millisecond)
+	s.NoError(err)
+	time.Sleep(10 * time.Millisecond)
+
+	// someone else took it after it expired, releasing must not remove their lock
+	next, err := locker.TryAcquire(ctx, "job", time.Minute)
+	s.NoError(err)
+
+	s.ErrorIs(lock.Release(ctx), NotHeldErr)
+	s.ErrorIs(lock.Extend(ctx, time.Minute), NotHeldErr)
+
+	_, err = locker.TryAcquire(ctx, "job", time.Minute)
+	s.ErrorIs(err, NotAcquiredErr)
+	s.NoError(next.Release(ctx))
+}
+
+func (s *LockTestSuite) TestAutoExtend() {
+	ctx := context.Background()
+	lock, err := s.locker.TryAcquire(ctx, "job", 30*time.Millisecond)
+	s.NoError(err)
+
+	time.Sleep(100 * time.Millisecond)
+	_, err = s.locker.TryAcquire(ctx, "job", time.Minute)
+	s.ErrorIs(err, NotAcquiredErr, "the lease should have been extended")
+
+	s.NoError(lock.Release(ctx))
+}
+
+func (s *LockTestSuite) TestAutoExtend_Lost() {
+	ctx := context.Background()
+	lock, err := s.locker.TryAcquire(ctx, "job", 30*time.Millisecond)
+	s.NoError(err)
+
+	// simulate someone else taking over the lease
+	s.store.mu.Lock()
+	s.store.leases["job"] = lease{token: "someone else", expiration: time.Now().Add(time.Minute)}
+	s.store.mu.Unlock()
+
+	select {
+	case <-lock.Lost():
+	case <-time.After(time.Second):
+		s.Fail("lock should have been lost")
+	}
+	s.ErrorIs(lock.Release(ctx), NotHeldErr)
+}
+
+func (s *LockTestSuite) TestAcquire_WaitsForRelease() {
+	ctx := context.Background()
+	lock, err := s.locker.TryAcquire(ctx, "job", time.Minute)
+	s.NoError(err)
+
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		lock.Release(ctx)
+	}()
+
+	waitCtx, cancel := context.WithTimeout(ctx, time.Second)
+	defer cancel()
+
+	next, err := s.locker.Acquire(waitCtx, "job", time.Minute)
+	s.NoError(err)
+	s.NoError(next.Release(ctx))
+}
+
+func (s *LockTestSuite) TestAcquire_ContextDone() {
+	lock, err := s.locker.TryAcquire(context.Background(), "job", time.Minute)
+	s.NoError(err)
+	defer lock.Release(context.Background())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	_, err = s.locker.Acquire(ctx, "job", time.Minute)
+	s.ErrorIs(err, context.DeadlineExceeded)
+}
+
+func (s *LockTestSuite) TestWithLock() {
+	ctx := context.Background()
+	var running atomic.Int32
+
+	err := s.locker.WithLock(ctx, "job", time.Minute, func(ctx context.Context) error {
+		running.Add(1)
+		_, err := s.locker.TryAcquire(ctx, "job", time.Minute)
+		s.ErrorIs(err, NotAcquiredErr)
+		return errors.New("job failed")
+	})
+	s.EqualError(err, "job failed")
+	s.Equal(int32(1), running.Load())
+
+	// released even though fn failed
+	lock, err := s.locker.TryAcquire(ctx, "job", time.Minute)
+	s.NoError(err)
+	s.NoError(lock.Release(ctx))
+}
+
+func (s *LockTestSuite) TestWithLock_CancelledWhenLost() {
+	err := s.locker.WithLock(context.Background(), "job", 30*time.Millisecond, func(ctx context.Context) error {
+		s.store.mu.Lock()
+		delete(s.store.leases, "job")
+		s.store.mu.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(time.Second):
+			return nil
+		}
+	})
+	s.ErrorIs(err, context.Canceled)
+}
@@ -0,0 +1,69 @@
+package lock
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+type lease struct {
+	token      string
+	expiration time.Time
+}
+
+// memoryStore keeps the leases in process, only goroutines sharing the locker compete
+type memoryStore struct {
+	mu     sync.Mutex
+	leases map[string]lease
+}
+
+// NewMemoryLocker keeps the leases in memory, for tests and single instance apps
+func NewMemoryLocker(opts ...Option) *locker {
+	return newLocker(&memoryStore{leases: make(map[string]lease)}, opts)
+}
+
+func (s *memoryStore) obtain(ctx context.Context, key, token string, ttl time.Duration) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, held := s.held(key); held {
+		return false, nil
+	}
+	s.leases[key] = lease{token: token, expiration: time.Now().Add(ttl)}
+	return true, nil
+}
+
+func (s *memoryStore) extend(ctx context.Context, key, token string, ttl time.Duration) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if l, held := s.held(key); !held || l.token != token {
+		return false, nil
+	}
+	s.leases[key] = lease{token: token, expiration: time.Now().Add(ttl)}
+	return true, nil
+}
+
+func (s *memoryStore) release(ctx context.Context, key, token string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if l, held := s.held(key); !held || l.token != token {
+		return false, nil
+	}
+	delete(s.leases, key)
+	return true, nil
+}
+
+// held must be called while holding the lock, expired leases are removed
+func (s *memoryStore) held(key string) (lease, bool) {
+	l, found := s.leases[key]
+	if !found {
+		return lease{}, false
+	}
+	if !time.Now().Before(l.expiration) {
+		delete(s.leases, key)
+		return lease{}, false
+	}
+	return l, true
+}
@@ -0,0 +1,49 @@
+package lock
+
+import "time"
+
+const (
+	DefaultMinBackoff = 10 * time.Millisecond
+	DefaultMaxBackoff = time.Second
+)
+
+// Option configures a Locker
+type Option func(*options)
+
+type options struct {
+	minBackoff time.Duration
+	maxBackoff time.Duration
+	autoExtend bool
+}
+
+func newOptions(opts []Option) *options {
+	o := &options{
+		minBackoff: DefaultMinBackoff,
+		maxBackoff: DefaultMaxBackoff,
+		autoExtend: true,
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// WithBackoff sets how long Acquire waits between attempts, starting at minWait and doubling up to maxWait.
+// Every wait is jittered so instances waiting on the same key spread out
+func WithBackoff(minWait, maxWait time.Duration) Option {
+	return func(o *options) {
+		if minWait <= 0 || maxWait < minWait {
+			return
+		}
+		o.minBackoff = minWait
+		o.maxBackoff = maxWait
+	}
+}
+
+// WithAutoExtend turns the background lease extension on or off, it is on by default.
+// With it off the lock is released once the ttl passes unless Extend is called
+func WithAutoExtend(enabled bool) Option {
+	return func(o *options) {
+		o.autoExtend = enabled
+	}
+}
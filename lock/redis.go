@@ -0,0 +1,46 @@
+package lock
+
+import (
+	"context"
+	"time"
+
+	redis "github.com/redis/go-redis/v9"
+)
+
+type redisStore struct {
+	client redis.UniversalClient
+}
+
+// NewRedisLocker stores the leases in redis so every instance using the same redis competes for the same keys.
+// Use the client from the redis cache, e.g. NewRedisLocker(redisCache.Client())
+func NewRedisLocker(client redis.UniversalClient, opts ...Option) *locker {
+	return newLocker(&redisStore{client: client}, opts)
+}
+
+func (s *redisStore) obtain(ctx context.Context, key, token string, ttl time.Duration) (bool, error) {
+	return s.client.SetNX(ctx, key, token, ttl).Result()
+}
+
+// extendScript resets the ttl of KEYS[1] to ARGV[2] milliseconds only if it still holds the token ARGV[1]
+var extendScript = redis.NewScript(`
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	return redis.call('PEXPIRE', KEYS[1], ARGV[2])
+end
+return 0
+`)
+
+func (s *redisStore) extend(ctx context.Context, key, token string, ttl time.Duration) (bool, error) {
+	return extendScript.Run(ctx, s.client, []string{key}, token, ttl.Milliseconds()).Bool()
+}
+
+// releaseScript deletes KEYS[1] only if it still holds the token ARGV[1], so a lock that expired and was taken by someone else is left alone
+var releaseScript = redis.NewScript(`
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	return redis.call('DEL', KEYS[1])
+end
+return 0
+`)
+
+func (s *redisStore) release(ctx context.Context, key, token string) (bool, error) {
+	return releaseScript.Run(ctx, s.client, []string{key}, token).Bool()
+}